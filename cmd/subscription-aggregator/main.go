@@ -1,7 +1,10 @@
 package main
 
 import (
+	"flag"
+	"os"
 	"subscription-aggregator/internal/app"
+	"subscription-aggregator/internal/config"
 	"subscription-aggregator/internal/deploy"
 	"subscription-aggregator/pkg/logger"
 
@@ -14,17 +17,42 @@ import (
 // @host localhost:8080
 // @BasePath /api
 // @schemes http
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT в формате "Bearer <token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API-ключ в формате "ApiKey <key>"
 func main() {
 	logger.InitLogger()
+
+	configPath := flag.String("config", app.DefaultConfigPath, "path to the configuration file")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Log.Fatalf("Config error: %v", err)
+	}
+	logger.Log.Info("Config loaded")
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := app.Migrate(cfg, args[1:], os.Stdout); err != nil {
+			logger.Log.Fatalf("Migration error: %v", err)
+		}
+		return
+	}
+
 	logger.Log.Info("Starting application setup...")
 
-	router, port, dbCloser, err := app.Setup()
+	router, port, dbCloser, err := app.Setup(cfg)
 	if err != nil {
 		logger.Log.Fatalf("Setup error: %v", err)
 	}
 	logger.Log.Infof("Setup completed successfully. Server will start on port %s", port)
 
-	if err := deploy.RunServer(router, port); err != nil {
+	if err := deploy.RunServer(router, port, cfg.Server); err != nil {
 		logger.Log.Fatalf("Server error: %v", err)
 	}
 	logger.Log.Info("Server has stopped running.")
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"subscription-aggregator/internal/config"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+	"subscription-aggregator/pkg/logger"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: migrate up | down [N] | status | to VERSION"
+
+// Migrate runs the migrate subcommand with its arguments and reports the
+// result to out.
+func Migrate(cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if driver := cfg.Storage.Driver; driver != "" && driver != config.StoragePostgres {
+		return fmt.Errorf("migrations only apply to %s, the %s storage creates its schema on startup", config.StoragePostgres, driver)
+	}
+	db := database.InitDB(cfg.GetDSN())
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		count, err = migrator.Up()
+	case command == "down" && len(args) <= 2:
+		n := 1
+		if len(args) == 2 {
+			if n, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		count, err = migrator.Down(n)
+	case command == "to" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid migration version %q", args[1])
+		}
+		count, err = migrator.To(version)
+	case command == "status" && len(args) == 1:
+		return printStatus(migrator, out)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if err != nil {
+		return err
+	}
+	logger.Log.Infof("%d migrations applied or reverted", count)
+	fmt.Fprintf(out, "%d migrations applied or reverted\n", count)
+	return nil
+}
+
+func printStatus(migrator *migrations.Migrator, out io.Writer) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+		if status.AppliedAt != nil {
+			state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+		}
+		switch {
+		case status.Unknown:
+			state = "unknown"
+		case status.Modified:
+			state = "modified"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
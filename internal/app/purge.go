@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/pkg/logger"
+	"time"
+)
+
+const defaultPurgeInterval = time.Hour
+
+// startPurge permanently removes, every interval, the subscriptions deleted
+// more than retention ago, and then the users deleted as long ago that have
+// none left. The returned function stops it, cancelling a purge
+// in progress.
+func startPurge(subs service.SubscriptionService, users service.UserService, retention, interval time.Duration) (stop func()) {
+	if retention <= 0 {
+		logger.Log.Info("retention.deleted_after is not set, deleted subscriptions are kept")
+		return func() {}
+	}
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+	logger.Log.Infof("Purging subscriptions deleted more than %s ago every %s", retention, interval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			before := time.Now().Add(-retention)
+			if _, err := subs.PurgeDeleted(ctx, before); err != nil {
+				logger.Log.Errorf("Error purging deleted subscriptions: %v", err)
+			} else if _, err := users.PurgeDeleted(ctx, before); err != nil {
+				logger.Log.Errorf("Error purging deleted users: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
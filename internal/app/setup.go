@@ -1,14 +1,17 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
 	"strings"
 	"subscription-aggregator/internal/config"
 	"subscription-aggregator/internal/handler"
 	"subscription-aggregator/internal/middleware"
 	"subscription-aggregator/internal/repository"
 	"subscription-aggregator/internal/service"
-	"subscription-aggregator/migrations"
-	"subscription-aggregator/pkg/database"
+	"subscription-aggregator/pkg/cursor"
 	"subscription-aggregator/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -16,12 +19,11 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func Setup() (router *gin.Engine, port string, dbCloser func() error, err error) {
-	cfg := config.LoadConfig("config/config.yaml")
-	logger.Log.Info("Config loaded")
-
-	dsn := cfg.GetDSN()
+// DefaultConfigPath is where the configuration is read from unless the
+// --config flag says otherwise.
+const DefaultConfigPath = "config/config.yaml"
 
+func Setup(cfg *config.Config) (router *gin.Engine, port string, dbCloser func() error, err error) {
 	port = cfg.Server.Port
 	if port == "" {
 		port = ":8080"
@@ -30,33 +32,114 @@ func Setup() (router *gin.Engine, port string, dbCloser func() error, err error)
 	}
 	logger.Log.Infof("Server will start on port %s", port)
 
-	db := database.InitDB(dsn)
-	logger.Log.Info("Database connection initialized")
+	store, err := openStorage(cfg)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	db := store.db
+
+	rateRepo := repository.NewExchangeRateRepository(db)
+	rateService := service.NewExchangeRateService(rateRepo)
+	rateHandler := handler.NewExchangeRateHandler(rateService)
 
-	if err := migrations.AutoMigrate(db); err != nil {
-		logger.Log.Fatalf("migration error: %v", err)
+	if cfg.Rates.CSVPath != "" {
+		if err := loadRates(rateService, cfg.Rates.CSVPath); err != nil {
+			return nil, "", nil, err
+		}
 	}
 
-	subRepo := repository.NewSubscriptionRepository(db)
-	subService := service.NewSubscriptionService(subRepo)
-	subHandler := handler.NewSubscriptionHandler(subService)
+	auditService := service.NewAuditService(store.audit)
+	subService := service.NewSubscriptionService(store.subs, store.tx, rateService)
+
+	userRepo := repository.NewUserRepository(db)
+	userService := service.NewUserService(userRepo, subService)
+	userHandler := handler.NewUserHandler(userService, auditService)
+
+	groupRepo := repository.NewGroupRepository(db)
+	groupService := service.NewGroupService(groupRepo)
+	groupHandler := handler.NewGroupHandler(groupService, userService)
+
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, userService)
+
+	cursorSecret, err := cursorSecret(cfg.Pagination.CursorSecret)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	subHandler := handler.NewSubscriptionHandler(subService, userService, groupService, auditService, cursor.NewSigner(cursorSecret))
 
 	router = gin.New()
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.LoggerMiddleware())
+	router.Use(middleware.ProblemMiddleware())
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	api := router.Group("/api")
+	authMiddleware, err := authMiddleware(cfg, apiKeyService)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	api := router.Group("/api",
+		middleware.TimeoutMiddleware(cfg.Database.QueryTimeout),
+		authMiddleware,
+		middleware.EnforceReadOnly("/api/subscriptions/:user_id/list"),
+	)
 	{
 		sub := api.Group("/subscriptions")
 		{
 			sub.POST("/:user_id", subHandler.CreateSubscriprion)
 			sub.GET("/:id", subHandler.GetSubscriptionByID)
 			sub.PUT("/:id", subHandler.UpdateSubscription)
+			sub.PATCH("/:id", subHandler.PatchSubscription)
 			sub.DELETE("/:id", subHandler.DeleteSubscription)
+			sub.POST("/restore/:id", subHandler.RestoreSubscription)
+			sub.GET("/:id/prices", subHandler.GetPriceHistory)
+			sub.GET("/:id/audit", subHandler.GetSubscriptionAudit)
+			sub.PUT("/:id/prices", subHandler.SchedulePrice)
+			sub.PUT("/:id/split", subHandler.SetSplit)
+			sub.DELETE("/:id/split", subHandler.DeleteSplit)
 			sub.GET("/user/:user_id/total", subHandler.GetTotal)
+			sub.GET("/user/:user_id/monthly", subHandler.GetMonthlySpending)
+			sub.GET("/user/:user_id/settlement", subHandler.GetSettlement)
 			sub.POST("/:user_id/list", subHandler.GetSubscriptionsList)
 		}
+
+		users := api.Group("/users")
+		{
+			users.POST("", userHandler.CreateUser)
+			users.GET("", middleware.RequireAdmin(), userHandler.GetUsersList)
+			users.GET("/:id", userHandler.GetUserByID)
+			users.DELETE("/:id", userHandler.DeleteUser)
+			users.GET("/:id/audit", userHandler.GetUserAudit)
+		}
+
+		groups := api.Group("/groups")
+		{
+			groups.POST("", groupHandler.CreateGroup)
+			groups.GET("", groupHandler.GetGroups)
+			groups.GET("/:id", groupHandler.GetGroup)
+			groups.DELETE("/:id", groupHandler.DeleteGroup)
+			groups.PUT("/:id/members/:user_id", groupHandler.SetGroupMember)
+			groups.DELETE("/:id/members/:user_id", groupHandler.RemoveGroupMember)
+			groups.GET("/:id/subscriptions", subHandler.GetGroupSubscriptions)
+			groups.GET("/:id/total", subHandler.GetGroupTotal)
+			groups.GET("/:id/monthly", subHandler.GetGroupMonthlySpending)
+		}
+
+		keys := api.Group("/keys")
+		{
+			keys.POST("", apiKeyHandler.IssueAPIKey)
+			keys.GET("", apiKeyHandler.GetAPIKeys)
+			keys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+		}
+
+		rates := api.Group("/rates")
+		{
+			rates.GET("", rateHandler.GetRates)
+			rates.POST("", middleware.RequireAdmin(), rateHandler.SetRates)
+		}
 	}
 
 	sqlDB, err := db.DB()
@@ -64,7 +147,10 @@ func Setup() (router *gin.Engine, port string, dbCloser func() error, err error)
 		return nil, "", nil, err
 	}
 
+	stopPurge := startPurge(subService, userService, cfg.Retention.DeletedAfter, cfg.Retention.PurgeInterval)
+
 	dbCloser = func() error {
+		stopPurge()
 		logger.Log.Info("Closing database connection")
 		return sqlDB.Close()
 	}
@@ -73,3 +159,47 @@ func Setup() (router *gin.Engine, port string, dbCloser func() error, err error)
 
 	return router, port, dbCloser, nil
 }
+
+func loadRates(rateService service.ExchangeRateService, path string) error {
+	logger.Log.Infof("Loading exchange rates from %s", path)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open exchange rates file: %w", err)
+	}
+	defer file.Close()
+
+	count, err := rateService.LoadCSV(context.Background(), file)
+	if err != nil {
+		return fmt.Errorf("load exchange rates from %s: %w", path, err)
+	}
+	logger.Log.Infof("Loaded %d exchange rates", count)
+	return nil
+}
+
+// authMiddleware checks JWTs and API keys on the API unless authentication is turned off.
+func authMiddleware(cfg *config.Config, apiKeys service.APIKeyService) (gin.HandlerFunc, error) {
+	auth := cfg.Auth
+	if !auth.Enabled {
+		logger.Log.Warn("Authentication is disabled, every request acts as an admin")
+		return middleware.NoAuthMiddleware(), nil
+	}
+	keys, err := middleware.NewKeySet(auth.HS256Secret, auth.RS256KeyPath, auth.JWKSPath)
+	if err != nil {
+		return nil, fmt.Errorf("load JWT keys: %w", err)
+	}
+	return middleware.AuthMiddleware(keys, auth.Issuer, auth.Audience, apiKeys), nil
+}
+
+// cursorSecret returns the key list cursors are signed with. Without a
+// configured one a random key is used, so cursors stop working on restart.
+func cursorSecret(configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+	logger.Log.Warn("pagination.cursor_secret is not set, using a random key")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate cursor secret: %w", err)
+	}
+	return secret, nil
+}
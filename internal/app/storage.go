@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"subscription-aggregator/internal/config"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+	"subscription-aggregator/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// storage is the database and the subscription repositories of the
+// configured storage driver.
+type storage struct {
+	db    *gorm.DB
+	subs  repository.SubscriptionRepository
+	audit repository.AuditRepository
+	tx    repository.Transactor
+}
+
+// openStorage connects to the storage selected in cfg. The memory driver
+// keeps subscriptions and their audit log in memory and everything else in
+// an in-memory SQLite database.
+func openStorage(cfg *config.Config) (*storage, error) {
+	switch cfg.Storage.Driver {
+	case "", config.StoragePostgres:
+		db := database.InitDB(cfg.GetDSN())
+		logger.Log.Info("Database connection initialized")
+
+		migrator, err := migrations.NewMigrator(db)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrator.Check(); err != nil {
+			return nil, fmt.Errorf("%w (run the migrate command first)", err)
+		}
+		return &storage{
+			db:    db,
+			subs:  repository.NewSubscriptionRepository(db),
+			audit: repository.NewAuditRepository(db),
+			tx:    repository.NewTransactor(db),
+		}, nil
+
+	case config.StorageSQLite:
+		if err := os.MkdirAll(filepath.Dir(cfg.Storage.SQLitePath), 0o755); err != nil {
+			return nil, fmt.Errorf("create SQLite directory: %w", err)
+		}
+		db := database.InitSQLite(cfg.Storage.SQLitePath)
+		logger.Log.Infof("SQLite database %s opened", cfg.Storage.SQLitePath)
+		if err := migrations.CreateSQLiteSchema(db); err != nil {
+			return nil, err
+		}
+		return &storage{
+			db:    db,
+			subs:  repository.NewSQLiteSubscriptionRepository(db),
+			audit: repository.NewAuditRepository(db),
+			tx:    repository.NewSQLiteTransactor(db),
+		}, nil
+
+	case config.StorageMemory:
+		logger.Log.Warn("Using in-memory storage, all data is lost on restart")
+		db := database.InitSQLite(":memory:")
+		if err := migrations.CreateSQLiteSchema(db); err != nil {
+			return nil, err
+		}
+		store := repository.NewMemoryStore(repository.NewUserRepository(db), repository.NewGroupRepository(db))
+		return &storage{
+			db:    db,
+			subs:  repository.NewMemorySubscriptionRepository(store),
+			audit: repository.NewMemoryAuditRepository(store),
+			tx:    repository.NewMemoryTransactor(store),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+}
@@ -0,0 +1,145 @@
+// Package apperror defines the errors the repositories, services and
+// handlers report to clients. Every error has a kind that decides the HTTP
+// status; errors of other types are internal errors.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindUnprocessable
+	KindUnavailable
+	KindTimeout
+	KindCanceled
+)
+
+// StatusClientClosedRequest is the nonstandard status of requests the client
+// gave up on before they were answered.
+const StatusClientClosedRequest = 499
+
+var statuses = map[Kind]int{
+	KindInternal:             http.StatusInternalServerError,
+	KindValidation:           http.StatusBadRequest,
+	KindUnauthorized:         http.StatusUnauthorized,
+	KindForbidden:            http.StatusForbidden,
+	KindNotFound:             http.StatusNotFound,
+	KindConflict:             http.StatusConflict,
+	KindPreconditionFailed:   http.StatusPreconditionFailed,
+	KindPreconditionRequired: http.StatusPreconditionRequired,
+	KindUnprocessable:        http.StatusUnprocessableEntity,
+	KindUnavailable:          http.StatusServiceUnavailable,
+	KindTimeout:              http.StatusGatewayTimeout,
+	KindCanceled:             StatusClientClosedRequest,
+}
+
+// Status returns the HTTP status of errors of the kind.
+func (k Kind) Status() int {
+	if status, ok := statuses[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an error with a kind. Message is shown to clients, the cause Err
+// is only logged.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  map[string]string // per-field messages of validation errors
+	Err     error
+
+	base *Error // the error Detailf added to
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the error e was made from with Detailf.
+func (e *Error) Is(target error) bool {
+	return e.base != nil && (target == e.base || e.base.Is(target))
+}
+
+// Detailf returns e with a formatted detail appended to its message. The
+// detail is shown to clients, so it must not contain internal errors.
+func (e *Error) Detailf(format string, args ...any) *Error {
+	return &Error{
+		Kind:    e.Kind,
+		Message: e.Message + ": " + fmt.Sprintf(format, args...),
+		Fields:  e.Fields,
+		Err:     e.Err,
+		base:    e,
+	}
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap returns an error of the kind caused by err.
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(KindUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, message)
+}
+
+// Validation reports invalid input, optionally with a message per field.
+func Validation(message string, fields map[string]string) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// Internal wraps an unexpected error; clients only see message.
+func Internal(message string, err error) *Error {
+	return Wrap(KindInternal, message, err)
+}
+
+// As returns the outermost *Error in the chain of err, if any.
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of err; errors without one are internal.
+func KindOf(err error) Kind {
+	if appErr, ok := As(err); ok {
+		return appErr.Kind
+	}
+	return KindInternal
+}
@@ -1,56 +1,197 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
+// Storage drivers selectable with storage.driver.
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
 type Config struct {
+	// Storage selects where data is kept: PostgreSQL (the default), a SQLite
+	// file or, for subscriptions and their audit log, the process memory.
+	Storage struct {
+		Driver     string `yaml:"driver"`
+		SQLitePath string `yaml:"sqlite_path"`
+	} `yaml:"storage"`
+
 	Database struct {
 		Host     string `yaml:"host"`
 		User     string `yaml:"user"`
-		Password string `yaml:"password"`
-		DBName   string `yaml:"dbname"`
-		Port     string `yaml:"port"`
-		SSLMode  string `yaml:"sslmode"`
+		Password string `yaml:"password" secret:"true"`
+		// PasswordFile is read for the password instead, e.g. a Docker secret.
+		PasswordFile string `yaml:"password_file"`
+		DBName       string `yaml:"dbname"`
+		Port         string `yaml:"port"`
+		SSLMode      string `yaml:"sslmode"`
+		// QueryTimeout bounds the queries of one API request.
+		QueryTimeout time.Duration `yaml:"query_timeout"`
 	} `yaml:"database"`
 
-	Server struct {
-		Port string `yaml:"port"`
-	} `yaml:"server"`
+	Server ServerConfig `yaml:"server"`
+
+	Rates struct {
+		CSVPath string `yaml:"csv_path"`
+	} `yaml:"rates"`
+
+	Retention struct {
+		DeletedAfter  time.Duration `yaml:"deleted_after"`
+		PurgeInterval time.Duration `yaml:"purge_interval"`
+	} `yaml:"retention"`
+
+	Pagination struct {
+		CursorSecret string `yaml:"cursor_secret" secret:"true"`
+	} `yaml:"pagination"`
+
+	Auth struct {
+		Enabled      bool   `yaml:"enabled"`
+		HS256Secret  string `yaml:"hs256_secret" secret:"true"`
+		RS256KeyPath string `yaml:"rs256_public_key_path"`
+		JWKSPath     string `yaml:"jwks_path"`
+		Issuer       string `yaml:"issuer"`
+		Audience     string `yaml:"audience"`
+	} `yaml:"auth"`
+}
+
+// ServerConfig configures the HTTP server. Zero timeouts and sizes leave the
+// net/http defaults in place, i.e. no timeout.
+type ServerConfig struct {
+	Port              string        `yaml:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	TLS               TLSConfig     `yaml:"tls"`
 }
 
-func LoadConfig(path string) *Config {
+// TLSConfig turns on HTTPS when both files are set. Changed files are loaded
+// again every ReloadInterval.
+type TLSConfig struct {
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// Enabled reports whether the server uses TLS.
+func (tls TLSConfig) Enabled() bool {
+	return tls.CertFile != "" && tls.KeyFile != ""
+}
+
+// redacted replaces the values of secret fields in logs.
+const redacted = "[REDACTED]"
+
+// LoadConfig reads the configuration from the YAML file at path, applies the
+// SUBAGG_* environment overrides and the password file, and validates the
+// result. All problems found after reading the file are returned together.
+func LoadConfig(path string) (*Config, error) {
 	log.Printf("Loading config from %s", path)
 
 	cfg := &Config{}
 
 	file, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("couldn't read config.yaml: %v", err)
+		return nil, fmt.Errorf("couldn't read config file: %w", err)
 	}
 	log.Printf("Config file %s successfully read, size: %d bytes", path, len(file))
 
 	err = yaml.Unmarshal(file, cfg)
 	if err != nil {
-		log.Fatalf("couldn't parse config.yaml: %v", err)
+		return nil, fmt.Errorf("couldn't parse config file %s: %w", path, err)
 	}
 	log.Printf("Config file %s successfully parsed", path)
 
-	return cfg
+	var errs []error
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.Database.PasswordFile != "" {
+		password, err := os.ReadFile(cfg.Database.PasswordFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't read database password file: %w", err))
+		} else {
+			cfg.Database.Password = strings.TrimRight(string(password), "\r\n")
+			log.Printf("Database password read from %s", cfg.Database.PasswordFile)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	log.Printf("Config loaded: %s", cfg)
+	return cfg, nil
+}
+
+// String formats the configuration for logs with the secrets redacted.
+func (context *Config) String() string {
+	redactedCfg := *context
+	redactSecrets(reflect.ValueOf(&redactedCfg).Elem())
+	return fmt.Sprintf("%+v", redactedCfg)
+}
+
+// redactSecrets replaces the non-empty fields tagged secret in value.
+func redactSecrets(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Time{}):
+			redactSecrets(field)
+		case value.Type().Field(i).Tag.Get("secret") == "true" && field.String() != "":
+			field.SetString(redacted)
+		}
+	}
 }
 
 func (context *Config) GetDSN() string {
+	shown := ""
+	if context.Database.Password != "" {
+		shown = redacted
+	}
+	log.Printf("Generated DSN for DB connection: %s", context.dsn(shown))
+	return context.dsn(context.Database.Password)
+}
+
+// dsn builds the DSN with the given password. Empty settings are left out so
+// that the driver defaults apply.
+func (context *Config) dsn(password string) string {
 	db := context.Database
-	dsn := "host=" + db.Host +
-		" user=" + db.User +
-		" password=" + db.Password +
-		" dbname=" + db.DBName +
-		" port=" + db.Port +
-		" sslmode=" + db.SSLMode
-
-	log.Printf("Generated DSN for DB connection: %s", dsn)
-	return dsn
+	var settings []string
+	for _, setting := range [][2]string{
+		{"host", db.Host},
+		{"user", db.User},
+		{"password", password},
+		{"dbname", db.DBName},
+		{"port", db.Port},
+		{"sslmode", db.SSLMode},
+	} {
+		if setting[1] != "" {
+			settings = append(settings, setting[0]+"="+dsnValue(setting[1]))
+		}
+	}
+	return strings.Join(settings, " ")
+}
+
+// dsnValue quotes a DSN value that contains spaces, quotes or backslashes.
+func dsnValue(value string) string {
+	if !strings.ContainsAny(value, " '\\\t\n") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"subscription-aggregator/internal/config"
+)
+
+func TestStringRedactsSecrets(t *testing.T) {
+	cfg := validConfig()
+	cfg.Database.Password = "db-password-1"
+	cfg.Pagination.CursorSecret = "cursor-secret-2"
+
+	shown := cfg.String()
+	for _, secret := range []string{cfg.Database.Password, cfg.Auth.HS256Secret, cfg.Pagination.CursorSecret} {
+		if strings.Contains(shown, secret) {
+			t.Errorf("String() = %s, shows secret %q", shown, secret)
+		}
+	}
+	if count := strings.Count(shown, "[REDACTED]"); count != 3 {
+		t.Errorf("String() = %s, has %d redacted values, want 3", shown, count)
+	}
+	if !strings.Contains(shown, "Host:localhost") {
+		t.Errorf("String() = %s, want the other settings shown", shown)
+	}
+	if cfg.Database.Password != "db-password-1" || cfg.Auth.HS256Secret == "[REDACTED]" {
+		t.Error("String() changed the configuration")
+	}
+}
+
+func TestStringKeepsEmptySecrets(t *testing.T) {
+	cfg := validConfig()
+	cfg.Auth.HS256Secret = ""
+	if shown := cfg.String(); strings.Contains(shown, "[REDACTED]") {
+		t.Errorf("String() = %s, redacts empty secrets", shown)
+	}
+}
+
+func TestGetDSNRedactsPasswordInLogs(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	cfg := validConfig()
+	cfg.Database.Password = "it's secret"
+	dsn := cfg.GetDSN()
+	if !strings.Contains(dsn, `password='it\'s secret'`) {
+		t.Errorf("GetDSN() = %s, want the quoted password", dsn)
+	}
+	if strings.Contains(logs.String(), "secret") || !strings.Contains(logs.String(), "password=[REDACTED]") {
+		t.Errorf("logs = %s, want the password redacted", logs.String())
+	}
+}
+
+func TestLoadConfigRedactsSecrets(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+database:
+  host: localhost
+  user: postgres
+  dbname: subscriptions
+auth:
+  enabled: true
+`), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("SUBAGG_DATABASE_PASSWORD", "env-password-3")
+	t.Setenv("SUBAGG_AUTH_HS256_SECRET", "q3J9vX2mL8rT5wZ1nB6cF4hK7pD0sG2a")
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Database.Password != "env-password-3" {
+		t.Errorf("password = %q, want the environment override", cfg.Database.Password)
+	}
+	for _, secret := range []string{"env-password-3", "q3J9vX2mL8rT5wZ1nB6cF4hK7pD0sG2a"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("logs show secret %q: %s", secret, logs.String())
+		}
+	}
+}
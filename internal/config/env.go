@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the names of the environment variables that override the
+// configuration file. The rest of a name is the YAML path of the field in
+// upper case, e.g. SUBAGG_DATABASE_HOST for database.host.
+const EnvPrefix = "SUBAGG"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides the fields of cfg that have an environment variable set,
+// using lookup to read them.
+func applyEnv(cfg *Config, lookup func(name string) (string, bool)) error {
+	var errs []error
+	overrideFields(reflect.ValueOf(cfg).Elem(), EnvPrefix, lookup, &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment overrides: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func overrideFields(value reflect.Value, prefix string, lookup func(string) (string, bool), errs *[]error) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		tag, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		if field.Kind() == reflect.Struct {
+			overrideFields(field, name, lookup, errs)
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+// setField parses raw into field according to its type.
+func setField(field reflect.Value, raw string) error {
+	switch {
+	case field.Type() == durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+	case field.Kind() == reflect.String:
+		field.SetString(raw)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case field.CanInt():
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case field.CanUint():
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
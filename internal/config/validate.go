@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// minHS256SecretLength is the shortest accepted HS256 secret: as many bytes as
+// the SHA-256 output, as RFC 7518 requires.
+const minHS256SecretLength = 32
+
+// placeholderSecrets are parts of example values that must never sign real
+// tokens. They are looked for anywhere in the secret, since examples are
+// padded to pass the length check, e.g. "change-me-to-a-32-byte-secret!!".
+var placeholderSecrets = []string{"change-me", "changeme", "change_me", "replace-me", "your-secret", "your_secret", "placeholder", "example"}
+
+// Validate checks the whole configuration and returns every problem found,
+// joined into one error.
+func (context *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch context.Storage.Driver {
+	case "", StoragePostgres:
+		db := context.Database
+		check(db.Host != "", "database.host is required")
+		check(db.User != "", "database.user is required")
+		check(db.DBName != "", "database.dbname is required")
+		check(db.Port == "" || validPort(db.Port), "database.port must be a port number, got %q", db.Port)
+		check(db.SSLMode == "" || contains(sslModes, db.SSLMode),
+			"database.sslmode must be one of %s, got %q", strings.Join(sslModes, ", "), db.SSLMode)
+	case StorageSQLite:
+		check(context.Storage.SQLitePath != "", "storage.sqlite_path is required for the %s driver", StorageSQLite)
+	case StorageMemory:
+	default:
+		check(false, "storage.driver must be one of %s, %s, %s, got %q",
+			StoragePostgres, StorageSQLite, StorageMemory, context.Storage.Driver)
+	}
+	server := context.Server
+	port := strings.TrimPrefix(server.Port, ":")
+	check(port == "" || validPort(port), "server.port must be a port number, got %q", server.Port)
+	check(server.MaxHeaderBytes >= 0, "server.max_header_bytes must not be negative")
+	check((server.TLS.CertFile == "") == (server.TLS.KeyFile == ""),
+		"server.tls.cert_file and server.tls.key_file must be set together")
+
+	for name, duration := range map[string]time.Duration{
+		"database.query_timeout":     context.Database.QueryTimeout,
+		"server.read_timeout":        server.ReadTimeout,
+		"server.read_header_timeout": server.ReadHeaderTimeout,
+		"server.write_timeout":       server.WriteTimeout,
+		"server.idle_timeout":        server.IdleTimeout,
+		"server.tls.reload_interval": server.TLS.ReloadInterval,
+		"retention.deleted_after":    context.Retention.DeletedAfter,
+		"retention.purge_interval":   context.Retention.PurgeInterval,
+	} {
+		check(duration >= 0, "%s must not be negative", name)
+		// A number without a unit is parsed as nanoseconds.
+		check(duration <= 0 || duration >= time.Millisecond,
+			"%s is %s, durations need a unit such as 10s", name, duration)
+	}
+
+	auth := context.Auth
+	check(!auth.Enabled || auth.HS256Secret != "" || auth.RS256KeyPath != "" || auth.JWKSPath != "",
+		"auth.enabled needs auth.hs256_secret, auth.rs256_public_key_path or auth.jwks_path")
+	if auth.Enabled && auth.HS256Secret != "" {
+		check(!containsAny(strings.ToLower(auth.HS256Secret), placeholderSecrets),
+			"auth.hs256_secret is a placeholder, set a random secret")
+		check(len(auth.HS256Secret) >= minHS256SecretLength,
+			"auth.hs256_secret must be at least %d bytes long", minHS256SecretLength)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/config"
+)
+
+// validConfig returns a configuration that passes validation.
+func validConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Database.Host = "localhost"
+	cfg.Database.User = "postgres"
+	cfg.Database.DBName = "subscriptions"
+	cfg.Database.Port = "5432"
+	cfg.Database.SSLMode = "disable"
+	cfg.Server.Port = ":8080"
+	cfg.Auth.Enabled = true
+	cfg.Auth.HS256Secret = "q3J9vX2mL8rT5wZ1nB6cF4hK7pD0sG2a"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(cfg *config.Config)
+		wantErr string // empty when the configuration is valid
+	}{
+		{name: "valid", change: func(*config.Config) {}},
+		{name: "default port and sslmode", change: func(cfg *config.Config) { cfg.Database.Port, cfg.Database.SSLMode, cfg.Server.Port = "", "", "" }},
+		{name: "missing host", change: func(cfg *config.Config) { cfg.Database.Host = "" }, wantErr: "database.host is required"},
+		{name: "port out of range", change: func(cfg *config.Config) { cfg.Database.Port = "70000" }, wantErr: "database.port must be a port number"},
+		{name: "unknown sslmode", change: func(cfg *config.Config) { cfg.Database.SSLMode = "on" }, wantErr: "database.sslmode must be one of"},
+		{name: "server port without colon", change: func(cfg *config.Config) { cfg.Server.Port = "9090" }},
+		{name: "server port not a number", change: func(cfg *config.Config) { cfg.Server.Port = ":http" }, wantErr: "server.port must be a port number"},
+		{name: "sqlite without path", change: func(cfg *config.Config) { cfg.Storage.Driver = config.StorageSQLite }, wantErr: "storage.sqlite_path is required"},
+		{name: "memory without database", change: func(cfg *config.Config) {
+			cfg.Storage.Driver = config.StorageMemory
+			cfg.Database.Host = ""
+		}},
+		{name: "unknown driver", change: func(cfg *config.Config) { cfg.Storage.Driver = "mysql" }, wantErr: "storage.driver must be one of"},
+		{name: "negative duration", change: func(cfg *config.Config) { cfg.Retention.DeletedAfter = -time.Hour }, wantErr: "retention.deleted_after must not be negative"},
+		{name: "duration without unit", change: func(cfg *config.Config) { cfg.Database.QueryTimeout = 30 }, wantErr: "durations need a unit"},
+		{name: "TLS key without certificate", change: func(cfg *config.Config) { cfg.Server.TLS.KeyFile = "key.pem" }, wantErr: "must be set together"},
+		{name: "auth without keys", change: func(cfg *config.Config) { cfg.Auth.HS256Secret = "" }, wantErr: "auth.enabled needs"},
+		{name: "auth with a JWKS file only", change: func(cfg *config.Config) {
+			cfg.Auth.HS256Secret = ""
+			cfg.Auth.JWKSPath = "jwks.json"
+		}},
+		{name: "short secret", change: func(cfg *config.Config) { cfg.Auth.HS256Secret = "q3J9vX2mL8rT5wZ1" }, wantErr: "at least 32 bytes"},
+		{name: "padded placeholder", change: func(cfg *config.Config) { cfg.Auth.HS256Secret = "change-me-to-a-32-byte-secret!!!" }, wantErr: "auth.hs256_secret is a placeholder"},
+		{name: "placeholder in upper case", change: func(cfg *config.Config) { cfg.Auth.HS256Secret = "YOUR-SECRET-GOES-HERE-0123456789" }, wantErr: "auth.hs256_secret is a placeholder"},
+		{name: "placeholder with auth off", change: func(cfg *config.Config) {
+			cfg.Auth.Enabled = false
+			cfg.Auth.HS256Secret = "changeme"
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := validConfig()
+			test.change(cfg)
+			err := cfg.Validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Validate = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Database.Host = ""
+	cfg.Database.Port = "0"
+	cfg.Auth.HS256Secret = "short"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate = nil, want an error")
+	}
+	for _, want := range []string{"database.host is required", "database.port must be a port number", "at least 32 bytes"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"subscription-aggregator/pkg/logger"
+	"sync"
+	"time"
+)
+
+// defaultReloadInterval is how often certificate files are checked when the
+// config doesn't say.
+const defaultReloadInterval = time.Minute
+
+// certReloader serves the certificate from certFile and keyFile and loads it
+// again when either file changes, so renewed certificates are picked up
+// without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version [2]fileVersion
+}
+
+// fileVersion tells whether a file has changed since it was last loaded.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	logger.Log.Infof("Loading TLS certificate from %s and %s", certFile, keyFile)
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		logger.Log.Errorf("Error loading TLS certificate: %v", err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is the tls.Config hook returning the current certificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watch checks the files every interval until ctx is done. A certificate that
+// fails to load is logged and the previous one stays in use.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.changed()
+			if err != nil {
+				logger.Log.Errorf("Error checking TLS certificate files: %v", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			logger.Log.Infof("TLS certificate files changed, reloading %s", r.certFile)
+			if err := r.reload(); err != nil {
+				logger.Log.Errorf("Error reloading TLS certificate, keeping the previous one: %v", err)
+				continue
+			}
+			logger.Log.Info("TLS certificate reloaded")
+		}
+	}
+}
+
+func (r *certReloader) changed() (bool, error) {
+	version, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return version != r.version, nil
+}
+
+func (r *certReloader) reload() error {
+	// Stat before loading so a write during the load is seen by the next check.
+	version, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A broken pair is remembered too, so it isn't retried until it changes.
+	r.version = version
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	return nil
+}
+
+func (r *certReloader) stat() ([2]fileVersion, error) {
+	var version [2]fileVersion
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return version, err
+		}
+		version[i] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return version, nil
+}
@@ -2,22 +2,58 @@ package deploy
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"subscription-aggregator/internal/config"
 	"subscription-aggregator/pkg/logger"
 	"time"
 )
 
-func RunServer(handler http.Handler, port string) error {
+// RunServer serves handler on port with the limits from cfg, over HTTPS when
+// cfg.TLS is enabled, until the process is interrupted.
+func RunServer(handler http.Handler, port string, cfg config.ServerConfig) error {
+	// Requests still running when the shutdown grace period ends have their
+	// context, and so their database queries, cancelled.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	srv := &http.Server{
-		Addr:    port,
-		Handler: handler,
+		Addr:              port,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+
+	listen := srv.ListenAndServe
+	if cfg.TLS.Enabled() {
+		reloader, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+		interval := cfg.TLS.ReloadInterval
+		if interval == 0 {
+			interval = defaultReloadInterval
+		}
+		go reloader.watch(baseCtx, interval)
+
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		// The certificate comes from TLSConfig.GetCertificate.
+		listen = func() error { return srv.ListenAndServeTLS("", "") }
 	}
 
 	go func() {
-		logger.Log.Infof("Starting server on port %s", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Log.Infof("Starting server on port %s (TLS: %t)", port, cfg.TLS.Enabled())
+		if err := listen(); err != nil && err != http.ErrServerClosed {
 			logger.Log.Fatalf("Listen error: %v", err)
 		}
 		logger.Log.Info("ListenAndServe exited")
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"net/http"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+	users   service.UserService
+}
+
+func NewAPIKeyHandler(s service.APIKeyService, users service.UserService) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: s,
+		users:   users,
+	}
+}
+
+// apiKeyRequest is the JSON body of the issue request.
+type apiKeyRequest struct {
+	UserID string            `json:"user_id" binding:"max=255"`
+	Name   string            `json:"name" binding:"required,max=255" example:"billing bot"`
+	Scope  model.APIKeyScope `json:"scope" binding:"required,oneof=read-only read-write admin" swaggertype:"string" example:"read-only"`
+}
+
+// checkKeyManagement answers 403 for requests made with a non-admin API key:
+// keys can't be used to mint or revoke other keys.
+func checkKeyManagement(context *gin.Context) bool {
+	if scope, ok := middleware.APIKeyScope(context); ok && scope != model.ScopeAdmin {
+		logger.Log.Warnf("API key management with a %s API key denied", scope)
+		middleware.Abort(context, apperror.Forbidden("API keys can't be managed with this API key"))
+		return false
+	}
+	return true
+}
+
+// keyOwner returns the user_id given in the request or the caller.
+func keyOwner(context *gin.Context, userID string) string {
+	if userID == "" {
+		userID, _ = middleware.Principal(context)
+	}
+	return userID
+}
+
+// @Summary Выпуск API-ключа
+// @Description Создает API-ключ пользователя (по умолчанию — текущего). Секрет возвращается только в этом ответе. Ключ с областью admin может выпустить только администратор
+// @Tags API-ключи
+// @Accept json
+// @Produce json
+// @Param key body apiKeyRequest true "API-ключ"
+// @Success 201 {object} model.IssuedAPIKey
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /keys [post]
+func (handler *APIKeyHandler) IssueAPIKey(context *gin.Context) {
+	logger.Log.Info("IssueAPIKey called")
+
+	if !checkKeyManagement(context) {
+		return
+	}
+	var req apiKeyRequest
+	if err := utils.BindJSON(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	userID := keyOwner(context, req.UserID)
+	if userID == "" {
+		middleware.Abort(context, utils.FieldErrors(map[string]string{"user_id": "is required"}))
+		return
+	}
+	if !checkAccess(context, userID) {
+		return
+	}
+	if _, admin := middleware.Principal(context); req.Scope == model.ScopeAdmin && !admin {
+		logger.Log.Warnf("Admin API key for %s requested by a non-admin", userID)
+		middleware.Abort(context, apperror.Forbidden("only admins can issue admin API keys"))
+		return
+	}
+	if _, err := handler.users.GetByID(context.Request.Context(), userID); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	key, err := handler.service.Issue(context.Request.Context(), userID, req.Name, req.Scope)
+	if err != nil {
+		logger.Log.Errorf("Failed to issue API key: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("API key %s issued for user %s", key.Prefix, userID)
+	context.JSON(http.StatusCreated, key)
+}
+
+// @Summary Список API-ключей
+// @Description Возвращает API-ключи пользователя (по умолчанию — текущего), включая отозванные, без секретов
+// @Tags API-ключи
+// @Produce json
+// @Param user_id query string false "ID пользователя"
+// @Success 200 {array} model.APIKey
+// @Failure 403 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /keys [get]
+func (handler *APIKeyHandler) GetAPIKeys(context *gin.Context) {
+	logger.Log.Info("GetAPIKeys called")
+
+	userID := keyOwner(context, context.Query("user_id"))
+	if !checkAccess(context, userID) {
+		return
+	}
+
+	keys, err := handler.service.GetByUser(context.Request.Context(), userID)
+	if err != nil {
+		logger.Log.Errorf("Error getting API keys: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, keys)
+}
+
+// @Summary Отзыв API-ключа
+// @Description Отзывает API-ключ; отозванный ключ больше не принимается
+// @Tags API-ключи
+// @Produce json
+// @Param id path string true "ID ключа"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /keys/{id} [delete]
+func (handler *APIKeyHandler) RevokeAPIKey(context *gin.Context) {
+	logger.Log.Info("RevokeAPIKey called")
+
+	if !checkKeyManagement(context) {
+		return
+	}
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid API key ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	key, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil {
+		logger.Log.Warnf("Error getting API key %s: %v", id, err)
+		middleware.Abort(context, err)
+		return
+	}
+	if !middleware.CanActAs(context, key.UserID) {
+		logger.Log.Warnf("API key %s of user %s is not accessible", id, key.UserID)
+		middleware.Abort(context, service.ErrAPIKeyNotFound)
+		return
+	}
+
+	if err := handler.service.Revoke(context.Request.Context(), id); err != nil {
+		logger.Log.Errorf("Error revoking API key: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("API key %s revoked", key.Prefix)
+	context.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// auditListRequest holds the query parameters of the audit log.
+type auditListRequest struct {
+	Page     int `form:"page" binding:"min=1"`
+	PageSize int `form:"page_size" binding:"min=1,max=100"`
+}
+
+// @Summary История изменений подписки
+// @Description Журнал изменений подписки от новых к старым: кто и когда изменил подписку и какие поля до и после. История удаленной подписки доступна ее пользователю
+// @Tags Подписки
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param page query integer false "Номер страницы" default(1)
+// @Param page_size query integer false "Размер страницы (до 100)" default(10)
+// @Success 200 {object} model.AuditPage
+// @Failure 400 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{id}/audit [get]
+func (handler *SubscriptionHandler) GetSubscriptionAudit(context *gin.Context) {
+	logger.Log.Info("GetSubscriptionAudit called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+	req := auditListRequest{Page: 1, PageSize: 10}
+	if err := utils.BindQuery(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	if !handler.checkAuditAccess(context, id) {
+		return
+	}
+
+	respondAudit(context, handler.audit, model.AuditFilter{SubscriptionID: &id}, req)
+}
+
+// checkAuditAccess fails the request with 404 unless the caller can see the
+// subscription or, once it is deleted, may act on behalf of its user.
+func (handler *SubscriptionHandler) checkAuditAccess(context *gin.Context, id uuid.UUID) bool {
+	sub, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil && !errors.Is(err, service.ErrSubscriptionNotFound) {
+		logger.Log.Errorf("Error getting subscription %s: %v", id, err)
+		middleware.Abort(context, err)
+		return false
+	}
+	if err == nil {
+		role, err := handler.subscriptionRole(context, sub)
+		if err != nil {
+			logger.Log.Errorf("Error checking access to subscription %s: %v", id, err)
+			middleware.Abort(context, err)
+			return false
+		}
+		if role == "" {
+			logger.Log.Warnf("Access to the history of subscription %s denied", id)
+			middleware.Abort(context, service.ErrSubscriptionNotFound)
+			return false
+		}
+		return true
+	}
+
+	entries, _, err := handler.audit.GetList(context.Request.Context(), model.AuditFilter{SubscriptionID: &id}, 0, 1)
+	if err != nil {
+		logger.Log.Errorf("Error getting the history of subscription %s: %v", id, err)
+		middleware.Abort(context, err)
+		return false
+	}
+	if len(entries) == 0 || !middleware.CanActAs(context, entries[0].UserID) {
+		logger.Log.Warnf("No accessible history of subscription %s", id)
+		middleware.Abort(context, service.ErrSubscriptionNotFound)
+		return false
+	}
+	return true
+}
+
+// @Summary История изменений подписок пользователя
+// @Description Журнал изменений подписок пользователя и изменений, сделанных им самим, от новых к старым
+// @Tags Пользователи
+// @Produce json
+// @Param id path string true "ID пользователя или me"
+// @Param page query integer false "Номер страницы" default(1)
+// @Param page_size query integer false "Размер страницы (до 100)" default(10)
+// @Success 200 {object} model.AuditPage
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /users/{id}/audit [get]
+func (handler *UserHandler) GetUserAudit(context *gin.Context) {
+	logger.Log.Info("GetUserAudit called")
+
+	id := userParam(context, "id")
+	if !checkAccess(context, id) {
+		return
+	}
+	req := auditListRequest{Page: 1, PageSize: 10}
+	if err := utils.BindQuery(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	respondAudit(context, handler.audit, model.AuditFilter{UserID: id}, req)
+}
+
+func respondAudit(context *gin.Context, audit service.AuditService, filter model.AuditFilter, req auditListRequest) {
+	entries, total, err := audit.GetList(context.Request.Context(), filter, (req.Page-1)*req.PageSize, req.PageSize)
+	if err != nil {
+		logger.Log.Errorf("Error getting audit entries: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, model.AuditPage{
+		Items:    entries,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
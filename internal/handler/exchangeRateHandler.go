@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeRateHandler struct {
+	service service.ExchangeRateService
+}
+
+func NewExchangeRateHandler(s service.ExchangeRateService) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		service: s,
+	}
+}
+
+// @Summary Курсы валют
+// @Description Возвращает таблицу курсов валют относительно RUB
+// @Tags Курсы валют
+// @Produce json
+// @Success 200 {array} model.ExchangeRate
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /rates [get]
+func (handler *ExchangeRateHandler) GetRates(context *gin.Context) {
+	logger.Log.Info("GetRates called")
+
+	rates, err := handler.service.GetRates(context.Request.Context())
+	if err != nil {
+		logger.Log.Errorf("Error getting exchange rates: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, rates)
+}
+
+// @Summary Загрузка курсов валют
+// @Description Добавляет или обновляет курсы валют. Принимает JSON-массив или CSV (text/csv) со строками "currency,rate"
+// @Tags Курсы валют
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param rates body []model.ExchangeRate true "Курсы валют"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /rates [post]
+func (handler *ExchangeRateHandler) SetRates(context *gin.Context) {
+	logger.Log.Info("SetRates called")
+
+	var count int
+	var err error
+	if strings.HasPrefix(context.ContentType(), "text/csv") {
+		count, err = handler.service.LoadCSV(context.Request.Context(), context.Request.Body)
+	} else {
+		var rates []model.ExchangeRate
+		if err := utils.BindJSON(context, &rates); err != nil {
+			middleware.Abort(context, err)
+			return
+		}
+		count, err = len(rates), handler.service.SetRates(context.Request.Context(), rates)
+	}
+
+	if err != nil {
+		logger.Log.Errorf("Error setting exchange rates: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("%d exchange rates updated", count)
+	context.JSON(http.StatusOK, gin.H{"updated": count})
+}
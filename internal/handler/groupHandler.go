@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"net/http"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type GroupHandler struct {
+	service service.GroupService
+	users   service.UserService
+}
+
+func NewGroupHandler(s service.GroupService, users service.UserService) *GroupHandler {
+	return &GroupHandler{
+		service: s,
+		users:   users,
+	}
+}
+
+// groupRequest is the JSON body of the create request.
+type groupRequest struct {
+	Name    string `json:"name" binding:"required,max=255" example:"Семья"`
+	OwnerID string `json:"owner_id" binding:"max=255"`
+}
+
+// memberRequest is the JSON body of the member update request.
+type memberRequest struct {
+	Role model.GroupRole `json:"role" binding:"required,oneof=owner editor viewer" swaggertype:"string" example:"viewer"`
+}
+
+// checkGroupRole fails the request with 404 unless the group exists and the
+// caller is a member, and 403 unless their role allows at least required.
+// Admins act as owners of every group.
+func checkGroupRole(context *gin.Context, groups service.GroupService, groupID uuid.UUID, required model.GroupRole) bool {
+	subject, admin := middleware.Principal(context)
+	role := model.RoleOwner
+	if admin {
+		if _, err := groups.GetByID(context.Request.Context(), groupID); err != nil {
+			middleware.Abort(context, err)
+			return false
+		}
+	} else {
+		var err error
+		if role, err = groups.Role(context.Request.Context(), groupID, subject); err != nil {
+			middleware.Abort(context, err)
+			return false
+		}
+	}
+
+	if role == "" {
+		logger.Log.Warnf("User %s is not a member of group %s", subject, groupID)
+		middleware.Abort(context, service.ErrGroupNotFound)
+		return false
+	}
+	if !role.Allows(required) {
+		logger.Log.Warnf("User %s is a %s of group %s, %s required", subject, role, groupID, required)
+		middleware.Abort(context, apperror.Forbidden("access denied"))
+		return false
+	}
+	return true
+}
+
+// checkGroupID parses the :id path parameter of group routes.
+func checkGroupID(context *gin.Context) (uuid.UUID, bool) {
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid group ID")
+		middleware.Abort(context, err)
+		return id, false
+	}
+	return id, true
+}
+
+// @Summary Создание группы
+// @Description Создает группу (семью или команду) для совместных подписок. Создатель (или owner_id) становится ее владельцем
+// @Tags Группы
+// @Accept json
+// @Produce json
+// @Param group body groupRequest true "Группа"
+// @Success 201 {object} model.Group
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups [post]
+func (handler *GroupHandler) CreateGroup(context *gin.Context) {
+	logger.Log.Info("CreateGroup called")
+
+	var req groupRequest
+	if err := utils.BindJSON(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	ownerID := keyOwner(context, req.OwnerID)
+	if ownerID == "" {
+		middleware.Abort(context, utils.FieldErrors(map[string]string{"owner_id": "is required"}))
+		return
+	}
+	if !checkAccess(context, ownerID) {
+		return
+	}
+	if _, err := handler.users.GetByID(context.Request.Context(), ownerID); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	group, err := handler.service.Create(context.Request.Context(), req.Name, ownerID)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Group created: %+v", group)
+	context.JSON(http.StatusCreated, group)
+}
+
+// @Summary Группы пользователя
+// @Description Возвращает группы, в которых состоит пользователь (по умолчанию — текущий)
+// @Tags Группы
+// @Produce json
+// @Param user_id query string false "ID пользователя"
+// @Success 200 {array} model.Group
+// @Failure 403 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups [get]
+func (handler *GroupHandler) GetGroups(context *gin.Context) {
+	logger.Log.Info("GetGroups called")
+
+	userID := keyOwner(context, context.Query("user_id"))
+	if !checkAccess(context, userID) {
+		return
+	}
+
+	groups, err := handler.service.GetByUser(context.Request.Context(), userID)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, groups)
+}
+
+// @Summary Получение группы
+// @Description Возвращает группу вместе с участниками и их ролями. Доступно всем участникам
+// @Tags Группы
+// @Produce json
+// @Param id path string true "ID группы"
+// @Success 200 {object} model.Group
+// @Failure 400 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id} [get]
+func (handler *GroupHandler) GetGroup(context *gin.Context) {
+	logger.Log.Info("GetGroup called")
+
+	id, ok := checkGroupID(context)
+	if !ok || !checkGroupRole(context, handler.service, id, model.RoleViewer) {
+		return
+	}
+
+	group, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, group)
+}
+
+// @Summary Удаление группы
+// @Description Удаляет группу. Подписки группы остаются у своих пользователей. Только для владельцев
+// @Tags Группы
+// @Produce json
+// @Param id path string true "ID группы"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id} [delete]
+func (handler *GroupHandler) DeleteGroup(context *gin.Context) {
+	logger.Log.Info("DeleteGroup called")
+
+	id, ok := checkGroupID(context)
+	if !ok || !checkGroupRole(context, handler.service, id, model.RoleOwner) {
+		return
+	}
+
+	if err := handler.service.Delete(context.Request.Context(), id); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Group %s deleted successfully", id)
+	context.JSON(http.StatusOK, gin.H{"message": "group deleted"})
+}
+
+// @Summary Добавление участника группы
+// @Description Добавляет пользователя в группу или меняет его роль (owner, editor, viewer). Только для владельцев; последнего владельца понизить нельзя
+// @Tags Группы
+// @Accept json
+// @Produce json
+// @Param id path string true "ID группы"
+// @Param user_id path string true "ID пользователя"
+// @Param member body memberRequest true "Роль"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 409 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id}/members/{user_id} [put]
+func (handler *GroupHandler) SetGroupMember(context *gin.Context) {
+	logger.Log.Info("SetGroupMember called")
+
+	id, ok := checkGroupID(context)
+	if !ok || !checkGroupRole(context, handler.service, id, model.RoleOwner) {
+		return
+	}
+	var req memberRequest
+	if err := utils.BindJSON(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	userID := userParam(context, "user_id")
+	if _, err := handler.users.GetByID(context.Request.Context(), userID); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	if err := handler.service.SetMember(context.Request.Context(), id, userID, req.Role); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("User %s is now a %s of group %s", userID, req.Role, id)
+	context.JSON(http.StatusOK, gin.H{"message": "group member updated"})
+}
+
+// @Summary Удаление участника группы
+// @Description Удаляет пользователя из группы. Доступно владельцам и самому участнику; последнего владельца удалить нельзя
+// @Tags Группы
+// @Produce json
+// @Param id path string true "ID группы"
+// @Param user_id path string true "ID пользователя или me"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 409 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id}/members/{user_id} [delete]
+func (handler *GroupHandler) RemoveGroupMember(context *gin.Context) {
+	logger.Log.Info("RemoveGroupMember called")
+
+	id, ok := checkGroupID(context)
+	if !ok {
+		return
+	}
+	userID := userParam(context, "user_id")
+	required := model.RoleOwner
+	if middleware.CanActAs(context, userID) {
+		required = model.RoleViewer
+	}
+	if !checkGroupRole(context, handler.service, id, required) {
+		return
+	}
+
+	if err := handler.service.RemoveMember(context.Request.Context(), id, userID); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("User %s removed from group %s", userID, id)
+	context.JSON(http.StatusOK, gin.H{"message": "group member removed"})
+}
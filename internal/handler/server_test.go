@@ -0,0 +1,108 @@
+//go:build cgo
+
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/handler"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/cursor"
+	"subscription-aggregator/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "handler-tests-hs256-secret-0123456789"
+
+// testServer serves the subscription and user routes from a SQLite database, with
+// JWT authentication, and exposes the services to set up test data.
+type testServer struct {
+	router *gin.Engine
+	users  service.UserService
+	groups service.GroupService
+	subs   service.SubscriptionService
+	audit  service.AuditService
+}
+
+func newTestServer(t *testing.T) *testServer {
+	db := database.InitSQLite(filepath.Join(t.TempDir(), "handler.db"))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrations.CreateSQLiteSchema(db); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	rates := service.NewExchangeRateService(repository.NewExchangeRateRepository(db))
+	subs := service.NewSubscriptionService(repository.NewSQLiteSubscriptionRepository(db), repository.NewSQLiteTransactor(db), rates)
+	server := &testServer{
+		users:  service.NewUserService(repository.NewUserRepository(db), subs),
+		groups: service.NewGroupService(repository.NewGroupRepository(db)),
+		subs:   subs,
+		audit:  service.NewAuditService(repository.NewAuditRepository(db)),
+	}
+	apiKeys := service.NewAPIKeyService(repository.NewAPIKeyRepository(db))
+	subHandler := handler.NewSubscriptionHandler(server.subs, server.users, server.groups, server.audit, cursor.NewSigner([]byte(testSecret)))
+	userHandler := handler.NewUserHandler(server.users, server.audit)
+
+	keys, err := middleware.NewKeySet(testSecret, "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	server.router = gin.New()
+	server.router.Use(middleware.ProblemMiddleware())
+	sub := server.router.Group("/api/subscriptions", middleware.AuthMiddleware(keys, "", "", apiKeys))
+	sub.GET("/:id", subHandler.GetSubscriptionByID)
+	sub.PUT("/:id", subHandler.UpdateSubscription)
+	sub.PATCH("/:id", subHandler.PatchSubscription)
+	sub.DELETE("/:id", subHandler.DeleteSubscription)
+	sub.POST("/restore/:id", subHandler.RestoreSubscription)
+	users := server.router.Group("/api/users", middleware.AuthMiddleware(keys, "", "", apiKeys))
+	users.DELETE("/:id", userHandler.DeleteUser)
+	return server
+}
+
+// token returns a JWT for subject that is valid for an hour.
+func token(t *testing.T, subject string, admin bool) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{
+		Admin: admin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// do sends a request with a JSON body, if any, and the given headers.
+func (s *testServer) do(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	var request *http.Request
+	if body == "" {
+		request = httptest.NewRequest(method, path, nil)
+	} else {
+		request = httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	return recorder
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"net/http"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// splitRequest is the JSON body of PUT /subscriptions/{id}/split.
+type splitRequest struct {
+	Method model.SplitMethod  `json:"method" binding:"required,oneof=equal percentage fixed" swaggertype:"string" enums:"equal,percentage,fixed"`
+	Shares []model.SplitShare `json:"shares" binding:"required,min=1,dive"`
+}
+
+// @Summary Разделение стоимости подписки
+// @Description Делит стоимость каждого списания подписки между пользователями: поровну (equal), в процентах (percentage, поле percent) или фиксированными суммами (fixed, поле amount). Оставшуюся часть платит владелец подписки
+// @Tags Подписки
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param split body splitRequest true "Способ разделения и доли"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Подписка или пользователь не найдены"
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{id}/split [put]
+func (handler *SubscriptionHandler) SetSplit(context *gin.Context) {
+	logger.Log.Info("SetSplit called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	var req splitRequest
+	if err := utils.BindJSON(context, &req); err != nil {
+		logger.Log.Warnf("Invalid split body: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	if _, ok := handler.getSubscription(context, id, model.RoleEditor); !ok {
+		return
+	}
+	for _, share := range req.Shares {
+		if _, err := handler.users.GetByID(context.Request.Context(), share.UserID); err != nil {
+			logger.Log.Warnf("Error getting share holder %s: %v", share.UserID, err)
+			middleware.Abort(context, err)
+			return
+		}
+	}
+
+	handler.setSplit(context, id, req.Method, req.Shares)
+}
+
+// @Summary Отмена разделения стоимости
+// @Description Удаляет доли пользователей, после чего всю стоимость подписки снова платит ее владелец
+// @Tags Подписки
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{id}/split [delete]
+func (handler *SubscriptionHandler) DeleteSplit(context *gin.Context) {
+	logger.Log.Info("DeleteSplit called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	if _, ok := handler.getSubscription(context, id, model.RoleEditor); !ok {
+		return
+	}
+
+	handler.setSplit(context, id, model.SplitNone, nil)
+}
+
+// setSplit stores the split and answers with the updated subscription.
+func (handler *SubscriptionHandler) setSplit(context *gin.Context, id uuid.UUID, method model.SplitMethod, shares []model.SplitShare) {
+	if err := handler.service.SetSplit(context.Request.Context(), id, method, shares, middleware.Actor(context)); err != nil {
+		logger.Log.Errorf("Error setting split: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	sub, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil {
+		logger.Log.Errorf("Error getting subscription: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Split of subscription %s set to %q with %d shares", id, method, len(shares))
+	context.JSON(http.StatusOK, sub)
+}
+
+// @Summary Взаиморасчеты по совместным подпискам
+// @Description Показывает, кто кому и сколько должен за период по подпискам с разделенной стоимостью, в которых участвует пользователь. Встречные долги взаимно погашаются
+// @Tags Подписки
+// @Produce json
+// @Param user_id path string true "ID пользователя или me"
+// @Param from query string false "Начальная дата (yyyy-mm-dd)"
+// @Param to query string false "Конечная дата (yyyy-mm-dd)"
+// @Param currency query string false "Валюта сумм (ISO 4217)" default(RUB)
+// @Success 200 {object} model.Settlement
+// @Failure 400 {object} middleware.Problem
+// @Failure 422 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Пользователь не найден"
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/user/{user_id}/settlement [get]
+func (handler *SubscriptionHandler) GetSettlement(context *gin.Context) {
+	logger.Log.Info("GetSettlement called")
+
+	userID := userParam(context, "user_id")
+	if !handler.checkUser(context, userID) {
+		return
+	}
+	from, to, err := utils.GetDate(context)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	currency, err := utils.GetCurrency(context, model.DefaultCurrency)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	settlement, err := handler.service.GetSettlement(context.Request.Context(), userID, currency, &from, to)
+	if err != nil {
+		logger.Log.Errorf("Error calculating settlement: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, settlement)
+}
@@ -1,62 +1,223 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/middleware"
 	"subscription-aggregator/internal/model"
 	"subscription-aggregator/internal/service"
 	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/cursor"
 	"subscription-aggregator/pkg/logger"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type SubscriptionHandler struct {
 	service service.SubscriptionService
+	users   service.UserService
+	groups  service.GroupService
+	audit   service.AuditService
+	cursors *cursor.Signer
 }
 
-func NewSubscriptionHandler(s service.SubscriptionService) *SubscriptionHandler {
+func NewSubscriptionHandler(s service.SubscriptionService, users service.UserService, groups service.GroupService, audit service.AuditService, cursors *cursor.Signer) *SubscriptionHandler {
 	return &SubscriptionHandler{
 		service: s,
+		users:   users,
+		groups:  groups,
+		audit:   audit,
+		cursors: cursors,
 	}
 }
 
+// checkUser fails the request with 403 unless the caller may act on behalf of
+// the user and 404 unless the user exists.
+func (handler *SubscriptionHandler) checkUser(context *gin.Context, userID string) bool {
+	if !checkAccess(context, userID) {
+		return false
+	}
+	if _, err := handler.users.GetByID(context.Request.Context(), userID); err != nil {
+		logger.Log.Warnf("Error getting user %s: %v", userID, err)
+		middleware.Abort(context, err)
+		return false
+	}
+	return true
+}
+
+// getSubscription fails the request with 404 unless the subscription exists
+// and the caller can see it, and 403 unless their role on it allows at least
+// required.
+func (handler *SubscriptionHandler) getSubscription(context *gin.Context, id uuid.UUID, required model.GroupRole) (*model.Subscription, bool) {
+	sub, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil {
+		logger.Log.Warnf("Error getting subscription: %v", err)
+		middleware.Abort(context, err)
+		return nil, false
+	}
+
+	role, err := handler.subscriptionRole(context, sub)
+	if err != nil {
+		logger.Log.Errorf("Error checking access to subscription %s: %v", id, err)
+		middleware.Abort(context, err)
+		return nil, false
+	}
+	if role == "" {
+		logger.Log.Warnf("Access to subscription %s of user %s denied", id, sub.UserID)
+		middleware.Abort(context, service.ErrSubscriptionNotFound)
+		return nil, false
+	}
+	if !role.Allows(required) {
+		logger.Log.Warnf("Subscription %s requires %s, caller is %s", id, required, role)
+		middleware.Abort(context, apperror.Forbidden("access denied"))
+		return nil, false
+	}
+	return sub, true
+}
+
+// subscriptionRole returns the caller's role on sub. The subscription's user
+// and admins are owners, members of its group have their group role and
+// users sharing its cost are at least viewers.
+func (handler *SubscriptionHandler) subscriptionRole(context *gin.Context, sub *model.Subscription) (model.GroupRole, error) {
+	if middleware.CanActAs(context, sub.UserID) {
+		return model.RoleOwner, nil
+	}
+	subject, _ := middleware.Principal(context)
+	if subject == "" {
+		return "", nil
+	}
+	var role model.GroupRole
+	for _, share := range sub.Shares {
+		if share.UserID == subject {
+			role = model.RoleViewer
+		}
+	}
+	if sub.GroupID == nil {
+		return role, nil
+	}
+	groupRole, err := handler.groups.Role(context.Request.Context(), *sub.GroupID, subject)
+	if err != nil || groupRole == "" {
+		return role, err
+	}
+	return groupRole, nil
+}
+
+// checkGroupChange makes sure the caller may move a subscription from one
+// group to another: only its owners can take it out of a group, and only
+// editors of the new group can put it there.
+func (handler *SubscriptionHandler) checkGroupChange(context *gin.Context, old, updated *model.Subscription) bool {
+	if equalGroups(old.GroupID, updated.GroupID) {
+		return true
+	}
+	if old.GroupID != nil {
+		role, err := handler.subscriptionRole(context, old)
+		if err != nil {
+			logger.Log.Errorf("Error checking access to subscription %s: %v", old.ID, err)
+			middleware.Abort(context, err)
+			return false
+		}
+		if !role.Allows(model.RoleOwner) {
+			logger.Log.Warnf("Moving subscription %s out of group %s denied", old.ID, *old.GroupID)
+			middleware.Abort(context, apperror.Forbidden("access denied"))
+			return false
+		}
+	}
+	return updated.GroupID == nil || checkGroupRole(context, handler.groups, *updated.GroupID, model.RoleEditor)
+}
+
+// checkUserChange makes sure the caller may hand a subscription over to
+// another user: only its owners can, and only to a user they may act as.
+func (handler *SubscriptionHandler) checkUserChange(context *gin.Context, old, updated *model.Subscription) bool {
+	if updated.UserID == old.UserID {
+		return true
+	}
+	role, err := handler.subscriptionRole(context, old)
+	if err != nil {
+		logger.Log.Errorf("Error checking access to subscription %s: %v", old.ID, err)
+		middleware.Abort(context, err)
+		return false
+	}
+	if !role.Allows(model.RoleOwner) {
+		logger.Log.Warnf("Changing the user of subscription %s denied", old.ID)
+		middleware.Abort(context, apperror.Forbidden("access denied"))
+		return false
+	}
+	return handler.checkUser(context, updated.UserID)
+}
+
+func equalGroups(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // @Summary Создание подписки
-// @Description Создает новую подписку
+// @Description Создает новую подписку. Данные принимаются JSON-телом или, если тела нет, query-параметрами
 // @Tags Подписки
+// @Accept json
 // @Produce json
-// @Param user_id path string true "ID пользователя"
-// @Param service_name query string true "Название сервиса"
-// @Param price query integer true "Стоимость подписки"
-// @Param from query string true "Начальная дата (yyyy-mm-dd)"
+// @Param user_id path string true "ID пользователя или me"
+// @Param subscription body subscriptionRequest false "Подписка"
+// @Param service_name query string false "Название сервиса"
+// @Param price query string false "Стоимость подписки (например, 299.90)"
+// @Param currency query string false "Валюта (ISO 4217)" default(RUB)
+// @Param billing_period query string false "Период оплаты (weekly, monthly, quarterly, yearly, custom)" default(monthly)
+// @Param billing_period_days query integer false "Длина периода в днях (для custom)"
+// @Param from query string false "Начальная дата (yyyy-mm-dd)"
 // @Param to query string false "Конечная дата (yyyy-mm-dd)"
 // @Success 201 {object} model.Subscription
-// @Failure 500 {object} map[string]string
+// @Header 201 {string} ETag "Версия подписки"
+// @Failure 400 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Пользователь не найден"
+// @Security BearerAuth || ApiKeyAuth
 // @Router /subscriptions/{user_id} [post]
 func (handler *SubscriptionHandler) CreateSubscriprion(context *gin.Context) {
 	logger.Log.Info("CreateSubscription called")
 
-	var newSub model.Subscription
+	newSub := model.Subscription{
+		UserID:        userParam(context, "user_id"),
+		Currency:      model.DefaultCurrency,
+		BillingPeriod: model.BillingMonthly,
+	}
 
-	newSub.UserID = context.Param("user_id")
-	newPrice, err := strconv.Atoi(context.Query("price"))
-	if err != nil {
-		logger.Log.Warnf("Invalid price query param: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid price"})
+	if utils.IsJSON(context) {
+		var req subscriptionRequest
+		if err := utils.BindJSON(context, &req); err != nil {
+			middleware.Abort(context, err)
+			return
+		}
+		if err := utils.FieldErrors(req.applyTo(&newSub, true)); err != nil {
+			middleware.Abort(context, err)
+			return
+		}
+	} else if err := bindCreateQuery(context, &newSub); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	if !handler.checkUser(context, newSub.UserID) {
 		return
 	}
-	newSub.Price = uint(newPrice)
-	newSub.ServiceName = context.Query("service_name")
-	newSub.StartDate, newSub.EndDate = utils.GetDate(context)
-	logger.Log.Infof("Creating subscription for user %s, service %s, price %d", newSub.UserID, newSub.ServiceName, newSub.Price)
+	if newSub.GroupID != nil && !checkGroupRole(context, handler.groups, *newSub.GroupID, model.RoleEditor) {
+		return
+	}
+
+	logger.Log.Infof("Creating subscription for user %s, service %s, price %s", newSub.UserID, newSub.ServiceName, newSub.Price)
 
-	if err := handler.service.Create(&newSub); err != nil {
+	if err := handler.service.Create(context.Request.Context(), &newSub, middleware.Actor(context)); err != nil {
 		logger.Log.Errorf("Failed to create subscription: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "impossible to create a subscription"})
+		middleware.Abort(context, err)
 		return
 	}
 
 	logger.Log.Infof("Subscription created: %+v", newSub)
+	utils.SetETag(context, newSub.Version)
 	context.JSON(http.StatusCreated, newSub)
 }
 
@@ -66,126 +227,153 @@ func (handler *SubscriptionHandler) CreateSubscriprion(context *gin.Context) {
 // @Produce json
 // @Param id path string true "ID подписки"
 // @Success 200 {object} model.Subscription
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Версия подписки"
+// @Failure 404 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
 // @Router /subscriptions/{id} [get]
 func (handler *SubscriptionHandler) GetSubscriptionByID(context *gin.Context) {
 	logger.Log.Info("GetSubscriptionByID called")
 
-	id, ok := utils.CheckID(context)
-	if !ok {
+	id, err := utils.CheckID(context)
+	if err != nil {
 		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
 		return
 	}
 	logger.Log.Infof("Fetching subscription by ID: %s", id.String())
 
-	sub, err := handler.service.GetByID(id)
-	if err != nil {
-		logger.Log.Warnf("Subscription not found: %v", err)
-		context.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	sub, ok := handler.getSubscription(context, id, model.RoleViewer)
+	if !ok {
 		return
 	}
 
+	utils.SetETag(context, sub.Version)
 	context.JSON(http.StatusOK, sub)
 }
 
 // @Summary Обновление подписки
-// @Description Обновляет существующую подписку по ID
+// @Description Обновляет существующую подписку по ID. Данные принимаются JSON-телом или, если тела нет, query-параметрами; непереданные поля не меняются. Новая цена действует с сегодняшнего дня, прошлые суммы не меняются
 // @Tags Подписки
 // @Accept json
 // @Produce json
 // @Param id path string true "ID подписки"
+// @Param subscription body subscriptionRequest false "Изменяемые поля подписки"
+// @Param If-Match header string true "ETag подписки из GET-запроса"
 // @Param user_id query string false "Новый ID пользователя"
 // @Param service_name query string false "Новое название сервиса"
-// @Param price query integer false "Новая стоимость подписки"
+// @Param price query string false "Новая стоимость подписки (например, 299.90)"
+// @Param currency query string false "Новая валюта (ISO 4217)"
+// @Param billing_period query string false "Новый период оплаты (weekly, monthly, quarterly, yearly, custom)"
+// @Param billing_period_days query integer false "Новая длина периода в днях (для custom)"
 // @Param from query string false "Новая начальная дата (yyyy-mm-dd)"
 // @Param to query string false "Новая конечная дата (yyyy-mm-dd)"
 // @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 412 {object} middleware.Problem
+// @Failure 428 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Подписка или пользователь не найдены"
+// @Security BearerAuth || ApiKeyAuth
 // @Router /subscriptions/{id} [put]
 func (handler *SubscriptionHandler) UpdateSubscription(context *gin.Context) {
 	logger.Log.Info("UpdateSubscription called")
 
-	id, ok := utils.CheckID(context)
-	if !ok {
+	id, err := utils.CheckID(context)
+	if err != nil {
 		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
 		return
 	}
 
-	oldSub, err := handler.service.GetByID(id)
-	if err != nil {
-		logger.Log.Warnf("Subscription not found: %v", err)
-		context.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	oldSub, ok := handler.getSubscription(context, id, model.RoleEditor)
+	if !ok {
 		return
 	}
 
-	var updatedSub model.Subscription
-
-	updatedSub.UserID = context.Query("user_id")
-	if updatedSub.UserID == "" {
-		updatedSub.UserID = oldSub.UserID
+	if _, err := utils.CheckIfMatch(context, oldSub.Version); err != nil {
+		middleware.Abort(context, err)
+		return
 	}
-	if priceStr := context.Query("price"); priceStr != "" {
-		updatePrice, err := strconv.Atoi(priceStr)
-		if err != nil {
-			logger.Log.Warnf("Invalid price query param: %v", err)
-			context.JSON(http.StatusBadRequest, gin.H{"error": "invalid price"})
+
+	updatedSub := *oldSub
+	updatedSub.PriceChanges = nil
+
+	if utils.IsJSON(context) {
+		var req subscriptionRequest
+		if err := utils.BindJSON(context, &req); err != nil {
+			middleware.Abort(context, err)
 			return
 		}
-		updatedSub.Price = uint(updatePrice)
-	} else {
-		updatedSub.Price = oldSub.Price
-	}
-	updatedSub.ServiceName = context.Query("service_name")
-	if updatedSub.ServiceName == "" {
-		updatedSub.ServiceName = oldSub.ServiceName
+		if err := utils.FieldErrors(req.applyTo(&updatedSub, false)); err != nil {
+			middleware.Abort(context, err)
+			return
+		}
+	} else if err := bindUpdateQuery(context, &updatedSub); err != nil {
+		middleware.Abort(context, err)
+		return
 	}
-	updatedSub.StartDate, updatedSub.EndDate = utils.GetDate(context)
-	if updatedSub.StartDate.IsZero() {
-		updatedSub.StartDate = oldSub.StartDate
+	updatedSub.ID = id
+
+	if !handler.checkUserChange(context, oldSub, &updatedSub) {
+		return
 	}
-	if updatedSub.EndDate == nil {
-		updatedSub.EndDate = oldSub.EndDate
+	if !handler.checkGroupChange(context, oldSub, &updatedSub) {
+		return
 	}
-	updatedSub.ID = id
 
 	logger.Log.Infof("Updating subscription ID %s: %+v", id.String(), updatedSub)
 
-	if err := handler.service.Update(&updatedSub); err != nil {
+	if err := handler.service.Update(context.Request.Context(), &updatedSub, middleware.Actor(context)); err != nil {
 		logger.Log.Errorf("Subscription update error: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "subscription update error"})
+		middleware.Abort(context, err)
 		return
 	}
 
 	logger.Log.Infof("Subscription %s updated successfully", id.String())
+	utils.SetETag(context, updatedSub.Version)
 	context.JSON(http.StatusOK, gin.H{"message": "the subscription has been updated"})
 }
 
 // @Summary Удаление подписки
-// @Description Удаляет подписку по ID
+// @Description Удаляет подписку по ID. Удаленную подписку можно восстановить, пока она не удалена окончательно по истечении срока хранения
 // @Tags Подписки
 // @Produce json
 // @Param id path string true "ID подписки"
+// @Param If-Match header string true "ETag подписки из GET-запроса"
 // @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 412 {object} middleware.Problem
+// @Failure 428 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
 // @Router /subscriptions/{id} [delete]
 func (handler *SubscriptionHandler) DeleteSubscription(context *gin.Context) {
 	logger.Log.Info("DeleteSubscription called")
 
-	id, ok := utils.CheckID(context)
-	if !ok {
+	id, err := utils.CheckID(context)
+	if err != nil {
 		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	sub, ok := handler.getSubscription(context, id, model.RoleOwner)
+	if !ok {
 		return
 	}
 
-	if _, err := handler.service.GetByID(id); err != nil {
-		logger.Log.Warnf("Subscription not found: %v", err)
-		context.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	version, err := utils.CheckIfMatch(context, sub.Version)
+	if err != nil {
+		middleware.Abort(context, err)
 		return
 	}
 
-	if err := handler.service.Delete(id); err != nil {
+	if err := handler.service.Delete(context.Request.Context(), id, version, middleware.Actor(context)); err != nil {
 		logger.Log.Errorf("Error deleting subscription: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "error when deleting a subscription"})
+		middleware.Abort(context, err)
 		return
 	}
 
@@ -194,70 +382,630 @@ func (handler *SubscriptionHandler) DeleteSubscription(context *gin.Context) {
 }
 
 // @Summary Список подписок
-// @Description Получение списка подписок по фильтру НИКНЕЙМ ПОЛЬЗОВАТЕЛЯ
+// @Description Получение страницы подписок пользователя с фильтрами и сортировкой, вместе с общим числом найденных подписок.
+// @Description Если передан параметр cursor (пустой для первой страницы), используется постраничный вывод по курсору:
+// @Description подписки упорядочены по start_date и id, в ответе вместо total и page возвращаются next_cursor и prev_cursor (model.SubscriptionCursorPage)
 // @Tags Подписки
 // @Accept json
 // @Produce json
-// @Param user_id path string true "user_id"
-// @Param page query integer true "page"
-// @Param page_size query integer false "page_size"
-// @Success 200 {array} model.Subscription
-// @Failure 500 {object} map[string]string
-// @Router /subscriptions/{user_id}/list/ [post]
+// @Param user_id path string true "ID пользователя или me"
+// @Param page query integer false "Номер страницы" default(1)
+// @Param cursor query string false "Курсор из next_cursor или prev_cursor предыдущего ответа"
+// @Param page_size query integer false "Размер страницы (до 100)" default(10)
+// @Param service_name query string false "Точное название сервиса"
+// @Param include_deleted query boolean false "Включать удаленные подписки"
+// @Param search query string false "Подстрока в названии сервиса (без учёта регистра)"
+// @Param price_min query string false "Минимальная текущая цена"
+// @Param price_max query string false "Максимальная текущая цена"
+// @Param active_at query string false "Активна на дату (yyyy-mm-dd)"
+// @Param started_from query string false "Начата не раньше (yyyy-mm-dd)"
+// @Param started_to query string false "Начата не позже (yyyy-mm-dd)"
+// @Param ended_from query string false "Завершена не раньше (yyyy-mm-dd)"
+// @Param ended_to query string false "Завершена не позже (yyyy-mm-dd)"
+// @Param sort query string false "Поле сортировки" Enums(service_name, price, currency, billing_period, start_date, end_date) default(start_date)
+// @Param order query string false "Направление сортировки" Enums(asc, desc) default(asc)
+// @Success 200 {object} model.SubscriptionPage
+// @Failure 400 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Пользователь не найден"
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{user_id}/list [post]
 func (handler *SubscriptionHandler) GetSubscriptionsList(context *gin.Context) {
 	logger.Log.Info("GetSubscriptionsList called")
 
-	var filters model.Subscription
-	filters.UserID = context.Param("user_id")
-	page, err := strconv.Atoi(context.Query("page"))
-	if err != nil || page < 1 {
-		logger.Log.Warnf("Invalid page query param: %v", err)
-		page = 1
+	userID := userParam(context, "user_id")
+	if !handler.checkUser(context, userID) {
+		return
+	}
+	handler.respondList(context, model.SubscriptionFilter{UserID: userID})
+}
+
+// respondList answers with a page of the subscriptions matching filters and
+// the list parameters from the query.
+func (handler *SubscriptionHandler) respondList(context *gin.Context, filters model.SubscriptionFilter) {
+	req := listRequest{Page: 1, PageSize: 10}
+	if err := utils.BindQuery(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	if err := utils.FieldErrors(req.toFilter(&filters)); err != nil {
+		middleware.Abort(context, err)
+		return
 	}
-	pageSize, err := strconv.Atoi(context.Query("page_size"))
-	if err != nil || pageSize < 1 {
-		pageSize = 10
+
+	if token, ok := context.GetQuery("cursor"); ok {
+		handler.getSubscriptionsPage(context, filters, token, req.PageSize)
+		return
 	}
 
-	logger.Log.Infof("Fetching subscriptions list for user %s, page %d, page_size %d", filters.UserID, page, pageSize)
+	logger.Log.Infof("Fetching subscriptions list for %+v, page %d, page_size %d", filters, req.Page, req.PageSize)
 
-	subs, err := handler.service.GetList(filters, (page-1)*pageSize, pageSize)
+	subs, total, err := handler.service.GetList(context.Request.Context(), filters, (req.Page-1)*req.PageSize, req.PageSize)
 	if err != nil {
 		logger.Log.Errorf("Error finding subscriptions: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "error in finding subscriptions"})
+		middleware.Abort(context, err)
 		return
 	}
 
-	context.JSON(http.StatusOK, subs)
+	context.JSON(http.StatusOK, model.SubscriptionPage{
+		Items:    subs,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// getSubscriptionsPage serves the cursor mode of the list: token is empty for
+// the first page or one of the cursors returned with a previous page.
+func (handler *SubscriptionHandler) getSubscriptionsPage(context *gin.Context, filters model.SubscriptionFilter, token string, pageSize int) {
+	fields := make(map[string]string)
+	if filters.SortBy != "" && filters.SortBy != "start_date" {
+		fields["sort"] = "must be start_date when paginating with a cursor"
+	}
+
+	var position *model.Cursor
+	if token != "" {
+		position = &model.Cursor{}
+		err := handler.cursors.Decode(token, filters, position)
+		if errors.Is(err, cursor.ErrMismatch) {
+			logger.Log.Warnf("Rejected cursor: %v", err)
+			fields["cursor"] = "was issued for a different filter or order"
+		} else if err != nil {
+			logger.Log.Warnf("Rejected cursor: %v", err)
+			fields["cursor"] = "is invalid"
+		}
+	}
+	if err := utils.FieldErrors(fields); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Fetching subscriptions page for %+v after cursor %+v, page_size %d", filters, position, pageSize)
+
+	page, err := handler.service.GetPage(context.Request.Context(), filters, position, pageSize)
+	if err != nil {
+		logger.Log.Errorf("Error finding subscriptions: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	resp := model.SubscriptionCursorPage{Items: page.Items, PageSize: pageSize}
+	if resp.NextCursor, err = handler.encodeCursor(filters, page.Next); err == nil {
+		resp.PrevCursor, err = handler.encodeCursor(filters, page.Prev)
+	}
+	if err != nil {
+		logger.Log.Errorf("Error encoding cursor: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, resp)
+}
+
+// encodeCursor returns a token for position that is only accepted for the
+// same filters.
+func (handler *SubscriptionHandler) encodeCursor(filters model.SubscriptionFilter, position *model.Cursor) (string, error) {
+	if position == nil {
+		return "", nil
+	}
+	return handler.cursors.Encode(filters, position)
 }
 
 // @Summary Сумма расходов
 // @Description Подсчет общей суммы расходов по подпискам пользователя за период
 // @Tags Подписки
 // @Produce json
-// @Param user_id path string true "ID пользователя"
+// @Param user_id path string true "ID пользователя или me"
 // @Param service_name query string false "Название сервиса"
+// @Param include_deleted query boolean false "Учитывать удаленные подписки"
 // @Param from query string false "Начальная дата (yyyy-mm-dd)"
 // @Param to query string false "Конечная дата (yyyy-mm-dd)"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Param currency query string false "Валюта итоговой суммы (ISO 4217)" default(RUB)
+// @Param split query boolean false "Считать долю пользователя в совместных подписках вместо полной стоимости оплачиваемых им"
+// @Success 200 {object} model.Total
+// @Failure 400 {object} middleware.Problem
+// @Failure 422 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Пользователь не найден"
+// @Security BearerAuth || ApiKeyAuth
 // @Router /subscriptions/user/{user_id}/total [get]
 func (handler *SubscriptionHandler) GetTotal(context *gin.Context) {
 	logger.Log.Info("GetTotal called")
 
-	userID := context.Param("user_id")
-	serviceName := context.Query("service_name")
-	from, to := utils.GetDate(context)
+	userID := userParam(context, "user_id")
+	split, err := utils.GetBool(context, "split")
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	if !handler.checkUser(context, userID) {
+		return
+	}
+	if split {
+		handler.respondTotal(context, model.SubscriptionFilter{ParticipantID: userID})
+		return
+	}
+	handler.respondTotal(context, model.SubscriptionFilter{UserID: userID})
+}
 
-	logger.Log.Infof("Calculating total for user %s, service '%s', from %v to %v", userID, serviceName, from, to)
+// respondTotal answers with the total of the subscriptions matching filter
+// over the period from the query. With filter.ParticipantID set only the
+// participant's shares are counted.
+func (handler *SubscriptionHandler) respondTotal(context *gin.Context, filter model.SubscriptionFilter) {
+	filter.ServiceName = context.Query("service_name")
+	var err error
+	if filter.IncludeDeleted, err = utils.GetBool(context, "include_deleted"); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	from, to, err := utils.GetDate(context)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	currency, err := utils.GetCurrency(context, model.DefaultCurrency)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
 
-	total, err := handler.service.GetTotal(userID, serviceName, &from, to)
+	logger.Log.Infof("Calculating total in %s for %+v, from %v to %v", currency, filter, from, to)
+
+	var total *model.Total
+	if filter.ParticipantID != "" {
+		total, err = handler.service.GetShareTotal(context.Request.Context(), filter, currency, &from, to)
+	} else {
+		total, err = handler.service.GetTotal(context.Request.Context(), filter, currency, &from, to)
+	}
 	if err != nil {
 		logger.Log.Errorf("Error calculating total: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "error in calculating the total"})
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, total)
+}
+
+const maxSpendingMonths = 120
+
+// @Summary Расходы по месяцам
+// @Description Помесячная разбивка расходов пользователя по сервисам за период (не более 120 месяцев)
+// @Tags Подписки
+// @Produce json
+// @Param user_id path string true "ID пользователя или me"
+// @Param service_name query string false "Название сервиса"
+// @Param include_deleted query boolean false "Учитывать удаленные подписки"
+// @Param from query string true "Начальная дата (yyyy-mm-dd)"
+// @Param to query string false "Конечная дата (yyyy-mm-dd), по умолчанию сегодня"
+// @Param currency query string false "Валюта сумм (ISO 4217)" default(RUB)
+// @Success 200 {object} model.SpendingSeries
+// @Failure 400 {object} middleware.Problem
+// @Failure 422 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Пользователь не найден"
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/user/{user_id}/monthly [get]
+func (handler *SubscriptionHandler) GetMonthlySpending(context *gin.Context) {
+	logger.Log.Info("GetMonthlySpending called")
+
+	userID := userParam(context, "user_id")
+	if !handler.checkUser(context, userID) {
+		return
+	}
+	handler.respondMonthly(context, model.SubscriptionFilter{UserID: userID})
+}
+
+// respondMonthly answers with the monthly spending on the subscriptions
+// matching filter over the period from the query.
+func (handler *SubscriptionHandler) respondMonthly(context *gin.Context, filter model.SubscriptionFilter) {
+	filter.ServiceName = context.Query("service_name")
+	var err error
+	if filter.IncludeDeleted, err = utils.GetBool(context, "include_deleted"); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	from, toPtr, err := utils.GetDate(context)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	if from.IsZero() {
+		logger.Log.Warn("Missing 'from' date")
+		middleware.Abort(context, utils.FieldErrors(map[string]string{"from": "is required"}))
+		return
+	}
+	to := time.Now().UTC()
+	if toPtr != nil {
+		to = *toPtr
+	}
+	if to.Before(from) {
+		logger.Log.Warnf("'to' date %v is before 'from' date %v", to, from)
+		middleware.Abort(context, utils.FieldErrors(map[string]string{"to": "must not be before from"}))
+		return
+	}
+	if months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month()) + 1; months > maxSpendingMonths {
+		logger.Log.Warnf("Requested %d months of spending, limit is %d", months, maxSpendingMonths)
+		middleware.Abort(context, apperror.Validation("the period is too long", map[string]string{"to": "must be at most 120 months after from"}))
+		return
+	}
+	currency, err := utils.GetCurrency(context, model.DefaultCurrency)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Calculating monthly spending in %s for %+v, from %v to %v", currency, filter, from, to)
+
+	series, err := handler.service.GetMonthly(context.Request.Context(), filter, currency, from, to)
+	if err != nil {
+		logger.Log.Errorf("Error calculating monthly spending: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, series)
+}
+
+// @Summary Подписки группы
+// @Description Получение страницы подписок группы с теми же фильтрами, сортировкой и постраничным выводом, что и у списка подписок пользователя. Доступно всем участникам группы
+// @Tags Группы
+// @Produce json
+// @Param id path string true "ID группы"
+// @Param page query integer false "Номер страницы" default(1)
+// @Param cursor query string false "Курсор из next_cursor или prev_cursor предыдущего ответа"
+// @Param page_size query integer false "Размер страницы (до 100)" default(10)
+// @Param service_name query string false "Точное название сервиса"
+// @Param include_deleted query boolean false "Включать удаленные подписки"
+// @Param search query string false "Подстрока в названии сервиса (без учёта регистра)"
+// @Param sort query string false "Поле сортировки" Enums(service_name, price, currency, billing_period, start_date, end_date) default(start_date)
+// @Param order query string false "Направление сортировки" Enums(asc, desc) default(asc)
+// @Success 200 {object} model.SubscriptionPage
+// @Failure 400 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id}/subscriptions [get]
+func (handler *SubscriptionHandler) GetGroupSubscriptions(context *gin.Context) {
+	logger.Log.Info("GetGroupSubscriptions called")
+
+	id, ok := checkGroupID(context)
+	if !ok || !checkGroupRole(context, handler.groups, id, model.RoleViewer) {
+		return
+	}
+	handler.respondList(context, model.SubscriptionFilter{GroupID: &id})
+}
+
+// @Summary Сумма расходов группы
+// @Description Подсчет общей суммы расходов по подпискам группы за период. Доступно всем участникам группы
+// @Tags Группы
+// @Produce json
+// @Param id path string true "ID группы"
+// @Param service_name query string false "Название сервиса"
+// @Param include_deleted query boolean false "Учитывать удаленные подписки"
+// @Param from query string false "Начальная дата (yyyy-mm-dd)"
+// @Param to query string false "Конечная дата (yyyy-mm-dd)"
+// @Param currency query string false "Валюта итоговой суммы (ISO 4217)" default(RUB)
+// @Success 200 {object} model.Total
+// @Failure 400 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 422 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id}/total [get]
+func (handler *SubscriptionHandler) GetGroupTotal(context *gin.Context) {
+	logger.Log.Info("GetGroupTotal called")
+
+	id, ok := checkGroupID(context)
+	if !ok || !checkGroupRole(context, handler.groups, id, model.RoleViewer) {
+		return
+	}
+	handler.respondTotal(context, model.SubscriptionFilter{GroupID: &id})
+}
+
+// @Summary Расходы группы по месяцам
+// @Description Помесячная разбивка расходов группы по сервисам за период (не более 120 месяцев). Доступно всем участникам группы
+// @Tags Группы
+// @Produce json
+// @Param id path string true "ID группы"
+// @Param service_name query string false "Название сервиса"
+// @Param include_deleted query boolean false "Учитывать удаленные подписки"
+// @Param from query string true "Начальная дата (yyyy-mm-dd)"
+// @Param to query string false "Конечная дата (yyyy-mm-dd), по умолчанию сегодня"
+// @Param currency query string false "Валюта сумм (ISO 4217)" default(RUB)
+// @Success 200 {object} model.SpendingSeries
+// @Failure 400 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 422 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /groups/{id}/monthly [get]
+func (handler *SubscriptionHandler) GetGroupMonthlySpending(context *gin.Context) {
+	logger.Log.Info("GetGroupMonthlySpending called")
+
+	id, ok := checkGroupID(context)
+	if !ok || !checkGroupRole(context, handler.groups, id, model.RoleViewer) {
+		return
+	}
+	handler.respondMonthly(context, model.SubscriptionFilter{GroupID: &id})
+}
+
+// @Summary История цен подписки
+// @Description Возвращает все изменения цены подписки, включая запланированные
+// @Tags Подписки
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Success 200 {array} model.PriceChange
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{id}/prices [get]
+func (handler *SubscriptionHandler) GetPriceHistory(context *gin.Context) {
+	logger.Log.Info("GetPriceHistory called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	if _, ok := handler.getSubscription(context, id, model.RoleViewer); !ok {
+		return
+	}
+
+	changes, err := handler.service.GetPriceHistory(context.Request.Context(), id)
+	if err != nil {
+		logger.Log.Errorf("Error getting price history: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, changes)
+}
+
+// @Summary Изменение цены подписки
+// @Description Устанавливает цену подписки начиная с даты from (по умолчанию сегодня). Будущая дата планирует изменение цены, изменение на ту же дату заменяет предыдущее. Дата раньше текущего месяца изменила бы уже посчитанные суммы, поэтому допускается только администратору с backdate=true
+// @Tags Подписки
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param price query string true "Новая стоимость подписки (например, 299.90)"
+// @Param from query string false "Дата, с которой действует цена (yyyy-mm-dd)"
+// @Param backdate query bool false "Разрешить дату раньше текущего месяца (только для администраторов)"
+// @Param If-Match header string true "ETag подписки из GET-запроса"
+// @Success 200 {object} model.PriceChange
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 412 {object} middleware.Problem
+// @Failure 428 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{id}/prices [put]
+func (handler *SubscriptionHandler) SchedulePrice(context *gin.Context) {
+	logger.Log.Info("SchedulePrice called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"sum": total})
+	price, err := model.ParseMoney(context.Query("price"))
+	if err != nil || price < 0 {
+		logger.Log.Warnf("Invalid price query param: %v", err)
+		middleware.Abort(context, errInvalidPrice)
+		return
+	}
+	from, _, err := utils.GetDate(context)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	if from.IsZero() {
+		from = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	backdate, err := utils.GetBool(context, "backdate")
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+	if _, admin := middleware.Principal(context); backdate && !admin {
+		logger.Log.Warn("Backdated price change denied")
+		middleware.Abort(context, apperror.Forbidden("only admins may backdate price changes"))
+		return
+	}
+
+	sub, ok := handler.getSubscription(context, id, model.RoleEditor)
+	if !ok {
+		return
+	}
+
+	version, err := utils.CheckIfMatch(context, sub.Version)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	change, err := handler.service.SchedulePrice(context.Request.Context(), id, version, price, from, backdate, middleware.Actor(context))
+	if err != nil {
+		logger.Log.Errorf("Error scheduling price: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Price of subscription %s set to %s from %s", id, price, from.Format("2006-01-02"))
+	utils.SetETag(context, version+1)
+	context.JSON(http.StatusOK, change)
+}
+
+// @Summary Частичное обновление подписки
+// @Description Изменяет только переданные поля подписки (JSON Merge Patch, RFC 7396). null удаляет необязательное поле, например "end_date": null возобновляет отменённую подписку. Новая цена действует с сегодняшнего дня
+// @Tags Подписки
+// @Accept json
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Param patch body subscriptionRequest true "Изменяемые поля подписки"
+// @Param If-Match header string true "ETag подписки из GET-запроса"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 400 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Подписка или пользователь не найдены"
+// @Failure 500 {object} middleware.Problem
+// @Failure 412 {object} middleware.Problem
+// @Failure 428 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/{id} [patch]
+func (handler *SubscriptionHandler) PatchSubscription(context *gin.Context) {
+	logger.Log.Info("PatchSubscription called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	oldSub, ok := handler.getSubscription(context, id, model.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if _, err := utils.CheckIfMatch(context, oldSub.Version); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	var req subscriptionRequest
+	removed, err := utils.BindMergePatch(context, &req)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	patchedSub := *oldSub
+	patchedSub.PriceChanges = nil
+	fields := removeFields(&patchedSub, removed)
+	for name, message := range req.applyTo(&patchedSub, false) {
+		fields[name] = message
+	}
+	if err := utils.FieldErrors(fields); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	if !handler.checkUserChange(context, oldSub, &patchedSub) {
+		return
+	}
+	if !handler.checkGroupChange(context, oldSub, &patchedSub) {
+		return
+	}
+
+	logger.Log.Infof("Patching subscription ID %s: %+v", id.String(), patchedSub)
+
+	if err := handler.service.Update(context.Request.Context(), &patchedSub, middleware.Actor(context)); err != nil {
+		logger.Log.Errorf("Subscription update error: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	sub, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil {
+		logger.Log.Errorf("Error reading patched subscription: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Subscription %s patched successfully", id.String())
+	utils.SetETag(context, sub.Version)
+	context.JSON(http.StatusOK, sub)
+}
+
+// @Summary Восстановление подписки
+// @Description Восстанавливает удаленную подписку, пока она не удалена окончательно
+// @Tags Подписки
+// @Produce json
+// @Param id path string true "ID подписки"
+// @Success 200 {object} model.Subscription
+// @Header 200 {string} ETag "Новая версия подписки"
+// @Failure 403 {object} middleware.Problem
+// @Failure 404 {object} middleware.Problem "Удаленная подписка или ее пользователь не найдены"
+// @Failure 500 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /subscriptions/restore/{id} [post]
+func (handler *SubscriptionHandler) RestoreSubscription(context *gin.Context) {
+	logger.Log.Info("RestoreSubscription called")
+
+	id, err := utils.CheckID(context)
+	if err != nil {
+		logger.Log.Warn("Invalid subscription ID")
+		middleware.Abort(context, err)
+		return
+	}
+
+	deleted, err := handler.service.GetDeleted(context.Request.Context(), id)
+	if err != nil {
+		logger.Log.Warnf("Deleted subscription not found: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+	role, err := handler.subscriptionRole(context, deleted)
+	if err != nil {
+		logger.Log.Errorf("Error checking access to subscription %s: %v", id, err)
+		middleware.Abort(context, err)
+		return
+	}
+	if role == "" {
+		logger.Log.Warnf("Access to deleted subscription %s of user %s denied", id, deleted.UserID)
+		middleware.Abort(context, service.ErrSubscriptionNotFound)
+		return
+	}
+	if !role.Allows(model.RoleOwner) {
+		logger.Log.Warnf("Restoring subscription %s requires owner, caller is %s", id, role)
+		middleware.Abort(context, apperror.Forbidden("access denied"))
+		return
+	}
+	// Subscriptions of deleted users stay deleted until they are purged.
+	if _, err := handler.users.GetByID(context.Request.Context(), deleted.UserID); err != nil {
+		logger.Log.Warnf("Error getting user %s: %v", deleted.UserID, err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	sub, err := handler.service.Restore(context.Request.Context(), id, middleware.Actor(context))
+	if err != nil {
+		logger.Log.Errorf("Error restoring subscription: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Subscription %s restored successfully", id)
+	utils.SetETag(context, sub.Version)
+	context.JSON(http.StatusOK, sub)
 }
@@ -0,0 +1,100 @@
+//go:build cgo
+
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+)
+
+// TestChangeSubscriptionUser checks that only owners of a subscription can
+// hand it over to another user, so that a group editor can't make themselves
+// its owner.
+func TestChangeSubscriptionUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		subject    string
+		admin      bool
+		wantStatus int
+		wantUser   string
+	}{
+		{
+			name:   "editor takes over with PUT",
+			method: http.MethodPut, body: `{"user_id": "bob"}`,
+			subject: "bob", wantStatus: http.StatusForbidden, wantUser: "alice",
+		},
+		{
+			name:   "editor takes over with PATCH",
+			method: http.MethodPatch, body: `{"user_id": "bob"}`,
+			subject: "bob", wantStatus: http.StatusForbidden, wantUser: "alice",
+		},
+		{
+			name:   "editor changes another field",
+			method: http.MethodPatch, body: `{"service_name": "Music"}`,
+			subject: "bob", wantStatus: http.StatusOK, wantUser: "alice",
+		},
+		{
+			name:   "owner hands over to a user they can't act as",
+			method: http.MethodPatch, body: `{"user_id": "bob"}`,
+			subject: "alice", wantStatus: http.StatusForbidden, wantUser: "alice",
+		},
+		{
+			name:   "admin hands over",
+			method: http.MethodPut, body: `{"user_id": "bob"}`,
+			subject: "root", admin: true, wantStatus: http.StatusOK, wantUser: "bob",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t)
+			ctx := context.Background()
+			for _, id := range []string{"alice", "bob"} {
+				if err := server.users.Create(ctx, &model.User{ID: id}); err != nil {
+					t.Fatalf("create user %s: %v", id, err)
+				}
+			}
+			group, err := server.groups.Create(ctx, "family", "alice")
+			if err != nil {
+				t.Fatalf("create group: %v", err)
+			}
+			if err := server.groups.SetMember(ctx, group.ID, "bob", model.RoleEditor); err != nil {
+				t.Fatalf("add editor: %v", err)
+			}
+			sub := &model.Subscription{
+				UserID:      "alice",
+				GroupID:     &group.ID,
+				ServiceName: "Video",
+				Price:       49900,
+				Currency:    model.DefaultCurrency,
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			if err := server.subs.Create(ctx, sub, model.Actor{UserID: "alice"}); err != nil {
+				t.Fatalf("create subscription: %v", err)
+			}
+
+			response := server.do(test.method, "/api/subscriptions/"+sub.ID.String(), test.body, map[string]string{
+				"Authorization": "Bearer " + token(t, test.subject, test.admin),
+				"If-Match":      strconv.Quote(strconv.FormatUint(uint64(sub.Version), 10)),
+			})
+			if response.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", response.Code, test.wantStatus, response.Body)
+			}
+
+			stored, err := server.subs.GetByID(ctx, sub.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if stored.UserID != test.wantUser {
+				t.Errorf("user = %s, want %s", stored.UserID, test.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// subscriptionRequest is the JSON body of create and update requests. It has
+// the same shape as model.Subscription; fields left out keep their current
+// (or default) value.
+type subscriptionRequest struct {
+	UserID            *string              `json:"user_id" binding:"omitempty,min=1,max=255"`
+	GroupID           *uuid.UUID           `json:"group_id" swaggertype:"string" format:"uuid"`
+	ServiceName       *string              `json:"service_name" binding:"omitempty,min=1,max=255"`
+	Price             *model.Money         `json:"price" binding:"omitempty,min=0" swaggertype:"string" example:"299.90"`
+	Currency          *string              `json:"currency" binding:"omitempty,len=3,alpha" example:"RUB"`
+	BillingPeriod     *model.BillingPeriod `json:"billing_period" binding:"omitempty,oneof=weekly monthly quarterly yearly custom" swaggertype:"string" example:"monthly"`
+	BillingPeriodDays *uint                `json:"billing_period_days" binding:"omitempty,min=1"`
+	StartDate         *string              `json:"start_date" example:"2025-01-01"`
+	EndDate           *string              `json:"end_date" example:"2025-12-31"`
+}
+
+// applyTo copies the provided fields onto sub and returns field-level
+// validation errors. On create the service name, price and start date are
+// required.
+func (req *subscriptionRequest) applyTo(sub *model.Subscription, create bool) map[string]string {
+	fields := make(map[string]string)
+
+	if req.UserID != nil {
+		if create && *req.UserID != sub.UserID {
+			fields["user_id"] = "must match the user in the path"
+		}
+		sub.UserID = *req.UserID
+	}
+	if req.GroupID != nil {
+		sub.GroupID = req.GroupID
+	}
+	if req.ServiceName != nil {
+		sub.ServiceName = *req.ServiceName
+	} else if create {
+		fields["service_name"] = "is required"
+	}
+	if req.Price != nil {
+		sub.Price = *req.Price
+	} else if create {
+		fields["price"] = "is required"
+	}
+	if req.Currency != nil {
+		currency, ok := model.NormalizeCurrency(*req.Currency)
+		if !ok {
+			fields["currency"] = "must be an ISO 4217 code"
+		}
+		sub.Currency = currency
+	}
+	if req.BillingPeriod != nil {
+		sub.BillingPeriod = *req.BillingPeriod
+		sub.BillingPeriodDays = 0
+	}
+	if req.BillingPeriodDays != nil {
+		sub.BillingPeriodDays = *req.BillingPeriodDays
+	}
+	if sub.BillingPeriod == model.BillingCustom && sub.BillingPeriodDays == 0 {
+		fields["billing_period_days"] = "is required for a custom billing period"
+	} else if sub.BillingPeriod != model.BillingCustom && req.BillingPeriodDays != nil {
+		fields["billing_period_days"] = "is only allowed for a custom billing period"
+	}
+	if req.StartDate != nil {
+		start, err := utils.ParseDate(*req.StartDate)
+		if err != nil {
+			fields["start_date"] = "must be a date (yyyy-mm-dd)"
+		}
+		sub.StartDate = start
+	} else if create {
+		fields["start_date"] = "is required"
+	}
+	if req.EndDate != nil {
+		end, err := utils.ParseDate(*req.EndDate)
+		if err != nil {
+			fields["end_date"] = "must be a date (yyyy-mm-dd)"
+		}
+		sub.EndDate = &end
+	}
+	if _, failed := fields["end_date"]; !failed && sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		fields["end_date"] = "must not be before start_date"
+	}
+
+	return fields
+}
+
+// errInvalidPrice is returned for a malformed price query parameter.
+var errInvalidPrice = apperror.Validation("invalid price", map[string]string{"price": "must be an amount such as 299.90"})
+
+// bindCreateQuery fills sub from the query-string form of the create request.
+func bindCreateQuery(context *gin.Context, sub *model.Subscription) error {
+	price, err := model.ParseMoney(context.Query("price"))
+	if err != nil || price < 0 {
+		logger.Log.Warnf("Invalid price query param: %v", err)
+		return errInvalidPrice
+	}
+	sub.Price = price
+	sub.ServiceName = context.Query("service_name")
+
+	period, periodDays, err := utils.GetBillingPeriod(context)
+	if err != nil {
+		return err
+	}
+	if period != "" {
+		sub.BillingPeriod, sub.BillingPeriodDays = period, periodDays
+	}
+	if sub.Currency, err = utils.GetCurrency(context, sub.Currency); err != nil {
+		return err
+	}
+
+	sub.StartDate, sub.EndDate, err = utils.GetDate(context)
+	return err
+}
+
+// bindUpdateQuery applies the query-string form of the update request on top
+// of the current subscription; empty parameters keep the current values.
+func bindUpdateQuery(context *gin.Context, sub *model.Subscription) error {
+	if userID := context.Query("user_id"); userID != "" {
+		sub.UserID = userID
+	}
+	if priceStr := context.Query("price"); priceStr != "" {
+		price, err := model.ParseMoney(priceStr)
+		if err != nil || price < 0 {
+			logger.Log.Warnf("Invalid price query param: %v", err)
+			return errInvalidPrice
+		}
+		sub.Price = price
+	}
+	if serviceName := context.Query("service_name"); serviceName != "" {
+		sub.ServiceName = serviceName
+	}
+
+	period, periodDays, err := utils.GetBillingPeriod(context)
+	if err != nil {
+		return err
+	}
+	if period != "" {
+		sub.BillingPeriod, sub.BillingPeriodDays = period, periodDays
+	}
+	if sub.Currency, err = utils.GetCurrency(context, sub.Currency); err != nil {
+		return err
+	}
+
+	start, end, err := utils.GetDate(context)
+	if err != nil {
+		return err
+	}
+	if !start.IsZero() {
+		sub.StartDate = start
+	}
+	if end != nil {
+		sub.EndDate = end
+	}
+	return nil
+}
+
+// removeFields applies the null members of a merge patch: optional fields go
+// back to their defaults, required ones cannot be removed.
+func removeFields(sub *model.Subscription, names []string) map[string]string {
+	fields := make(map[string]string)
+	for _, name := range names {
+		switch name {
+		case "end_date":
+			sub.EndDate = nil
+		case "group_id":
+			sub.GroupID = nil
+		case "currency":
+			sub.Currency = model.DefaultCurrency
+		case "billing_period":
+			sub.BillingPeriod, sub.BillingPeriodDays = model.BillingMonthly, 0
+		case "billing_period_days":
+			sub.BillingPeriodDays = 0
+		case "user_id", "service_name", "price", "start_date":
+			fields[name] = "cannot be removed"
+		default:
+			fields[name] = "unknown field"
+		}
+	}
+	return fields
+}
+
+// listRequest holds the query parameters of the subscription list.
+type listRequest struct {
+	Page           int        `form:"page" binding:"min=1"`
+	PageSize       int        `form:"page_size" binding:"min=1,max=100"`
+	ServiceName    string     `form:"service_name" binding:"max=255"`
+	Search         string     `form:"search" binding:"max=255"`
+	PriceMin       string     `form:"price_min"`
+	PriceMax       string     `form:"price_max"`
+	ActiveAt       *time.Time `form:"active_at" time_format:"2006-01-02"`
+	StartedFrom    *time.Time `form:"started_from" time_format:"2006-01-02"`
+	StartedTo      *time.Time `form:"started_to" time_format:"2006-01-02"`
+	EndedFrom      *time.Time `form:"ended_from" time_format:"2006-01-02"`
+	EndedTo        *time.Time `form:"ended_to" time_format:"2006-01-02"`
+	Sort           string     `form:"sort" binding:"omitempty,oneof=service_name price currency billing_period start_date end_date"`
+	Order          string     `form:"order" binding:"omitempty,oneof=asc desc"`
+	IncludeDeleted bool       `form:"include_deleted"`
+}
+
+// toFilter converts the request into a repository filter and returns
+// field-level validation errors.
+func (req *listRequest) toFilter(filter *model.SubscriptionFilter) map[string]string {
+	fields := make(map[string]string)
+
+	filter.ServiceName = req.ServiceName
+	filter.ServiceNameContains = req.Search
+	filter.ActiveAt = req.ActiveAt
+	filter.StartedFrom, filter.StartedTo = req.StartedFrom, req.StartedTo
+	filter.EndedFrom, filter.EndedTo = req.EndedFrom, req.EndedTo
+	filter.SortBy = req.Sort
+	filter.SortDesc = req.Order == "desc"
+	filter.IncludeDeleted = req.IncludeDeleted
+
+	if req.PriceMin != "" {
+		price, err := model.ParseMoney(req.PriceMin)
+		if err != nil {
+			fields["price_min"] = "must be an amount such as 299.90"
+		}
+		filter.PriceMin = &price
+	}
+	if req.PriceMax != "" {
+		price, err := model.ParseMoney(req.PriceMax)
+		if err != nil {
+			fields["price_max"] = "must be an amount such as 299.90"
+		}
+		filter.PriceMax = &price
+	}
+	if len(fields) == 0 && filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		fields["price_max"] = "must not be less than price_min"
+	}
+	if filter.StartedFrom != nil && filter.StartedTo != nil && filter.StartedTo.Before(*filter.StartedFrom) {
+		fields["started_to"] = "must not be before started_from"
+	}
+	if filter.EndedFrom != nil && filter.EndedTo != nil && filter.EndedTo.Before(*filter.EndedFrom) {
+		fields["ended_to"] = "must not be before ended_from"
+	}
+
+	return fields
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"net/http"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/internal/utils"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	service service.UserService
+	audit   service.AuditService
+}
+
+func NewUserHandler(s service.UserService, audit service.AuditService) *UserHandler {
+	return &UserHandler{
+		service: s,
+		audit:   audit,
+	}
+}
+
+// userRequest is the JSON body of the create request.
+type userRequest struct {
+	ID    string `json:"id" binding:"max=255" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Name  string `json:"name" binding:"max=255"`
+	Email string `json:"email" binding:"omitempty,email,max=255"`
+}
+
+// usersListRequest holds the query parameters of the user list.
+type usersListRequest struct {
+	Page     int `form:"page" binding:"min=1"`
+	PageSize int `form:"page_size" binding:"min=1,max=100"`
+}
+
+// @Summary Создание пользователя
+// @Description Создает пользователя. Если id не передан, он генерируется (для пользователя без прав администратора — берется из токена)
+// @Tags Пользователи
+// @Accept json
+// @Produce json
+// @Param user body userRequest true "Пользователь"
+// @Success 201 {object} model.User
+// @Failure 400 {object} middleware.Problem
+// @Failure 409 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /users [post]
+func (handler *UserHandler) CreateUser(context *gin.Context) {
+	logger.Log.Info("CreateUser called")
+
+	var req userRequest
+	if err := utils.BindJSON(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	// Only admins may register other users; everyone else registers
+	// themselves.
+	if subject, admin := middleware.Principal(context); !admin {
+		if req.ID == "" {
+			req.ID = subject
+		}
+		if req.ID != subject {
+			logger.Log.Warnf("User %s tried to create user %s", subject, req.ID)
+			middleware.Abort(context, apperror.Forbidden("access denied"))
+			return
+		}
+	}
+
+	user := model.User{ID: req.ID, Name: req.Name, Email: req.Email}
+	if err := handler.service.Create(context.Request.Context(), &user); err != nil {
+		logger.Log.Errorf("Failed to create user: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("User created: %+v", user)
+	context.JSON(http.StatusCreated, user)
+}
+
+// @Summary Получение пользователя по ID
+// @Description Возвращает пользователя по его идентификатору
+// @Tags Пользователи
+// @Produce json
+// @Param id path string true "ID пользователя или me"
+// @Success 200 {object} model.User
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /users/{id} [get]
+func (handler *UserHandler) GetUserByID(context *gin.Context) {
+	logger.Log.Info("GetUserByID called")
+
+	id := userParam(context, "id")
+	if !checkAccess(context, id) {
+		return
+	}
+
+	user, err := handler.service.GetByID(context.Request.Context(), id)
+	if err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, user)
+}
+
+// @Summary Список пользователей
+// @Description Получение страницы пользователей, упорядоченных по ID. Только для администраторов
+// @Tags Пользователи
+// @Produce json
+// @Param page query integer false "Номер страницы" default(1)
+// @Param page_size query integer false "Размер страницы (до 100)" default(10)
+// @Success 200 {object} model.UserPage
+// @Failure 400 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /users [get]
+func (handler *UserHandler) GetUsersList(context *gin.Context) {
+	logger.Log.Info("GetUsersList called")
+
+	req := usersListRequest{Page: 1, PageSize: 10}
+	if err := utils.BindQuery(context, &req); err != nil {
+		middleware.Abort(context, err)
+		return
+	}
+
+	users, total, err := handler.service.GetList(context.Request.Context(), (req.Page-1)*req.PageSize, req.PageSize)
+	if err != nil {
+		logger.Log.Errorf("Error finding users: %v", err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, model.UserPage{
+		Items:    users,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// @Summary Удаление пользователя
+// @Description Помечает удаленными пользователя и все его подписки, отзывает его ключи API и исключает его из групп
+// @Tags Пользователи
+// @Produce json
+// @Param id path string true "ID пользователя или me"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} middleware.Problem
+// @Failure 500 {object} middleware.Problem
+// @Failure 403 {object} middleware.Problem
+// @Security BearerAuth || ApiKeyAuth
+// @Router /users/{id} [delete]
+func (handler *UserHandler) DeleteUser(context *gin.Context) {
+	logger.Log.Info("DeleteUser called")
+
+	id := userParam(context, "id")
+	if !checkAccess(context, id) {
+		return
+	}
+
+	if err := handler.service.Delete(context.Request.Context(), id, middleware.Actor(context)); err != nil {
+		logger.Log.Errorf("Error deleting user %s: %v", id, err)
+		middleware.Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("User %s deleted successfully", id)
+	context.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
+
+// userParam returns a user ID path parameter, "me" standing for the
+// authenticated user.
+func userParam(context *gin.Context, name string) string {
+	id := context.Param(name)
+	if subject, _ := middleware.Principal(context); id == "me" && subject != "" {
+		return subject
+	}
+	return id
+}
+
+// checkAccess fails the request with 403 unless the caller may act on behalf
+// of the user.
+func checkAccess(context *gin.Context, userID string) bool {
+	if !middleware.CanActAs(context, userID) {
+		logger.Log.Warnf("Access to user %s denied", userID)
+		middleware.Abort(context, apperror.Forbidden("access denied"))
+		return false
+	}
+	return true
+}
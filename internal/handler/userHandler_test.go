@@ -0,0 +1,70 @@
+//go:build cgo
+
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+)
+
+// TestDeleteUser checks that deleting a user only marks their subscriptions
+// deleted, recording it in the audit log, and that the user is purged with
+// the last of them.
+func TestDeleteUser(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	if err := server.users.Create(ctx, &model.User{ID: "alice"}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sub := &model.Subscription{
+		UserID:      "alice",
+		ServiceName: "Video",
+		Price:       49900,
+		Currency:    model.DefaultCurrency,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := server.subs.Create(ctx, sub, model.Actor{UserID: "alice"}); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	admin := map[string]string{"Authorization": "Bearer " + token(t, "root", true)}
+
+	if response := server.do(http.MethodDelete, "/api/users/alice", "", admin); response.Code != http.StatusOK {
+		t.Fatalf("delete user: status = %d: %s", response.Code, response.Body)
+	}
+	if _, err := server.users.GetByID(ctx, "alice"); !errors.Is(err, service.ErrUserNotFound) {
+		t.Errorf("GetByID of the deleted user = %v, want %v", err, service.ErrUserNotFound)
+	}
+	if _, err := server.subs.GetDeleted(ctx, sub.ID); err != nil {
+		t.Errorf("subscription of the deleted user is not marked deleted: %v", err)
+	}
+	entries, _, err := server.audit.GetList(ctx, model.AuditFilter{SubscriptionID: &sub.ID}, 0, 10)
+	if err != nil {
+		t.Fatalf("get audit log: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != model.AuditDelete || entries[0].Actor != "root" {
+		t.Errorf("audit log = %+v, want the deletion by root last", entries)
+	}
+	if response := server.do(http.MethodPost, "/api/subscriptions/restore/"+sub.ID.String(), "", admin); response.Code != http.StatusNotFound {
+		t.Errorf("restore: status = %d, want %d: %s", response.Code, http.StatusNotFound, response.Body)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if purged, err := server.users.PurgeDeleted(ctx, later); err != nil || purged != 0 {
+		t.Errorf("PurgeDeleted with a subscription left = %d, %v, want 0, nil", purged, err)
+	}
+	if _, err := server.subs.PurgeDeleted(ctx, later); err != nil {
+		t.Fatalf("purge subscriptions: %v", err)
+	}
+	if purged, err := server.users.PurgeDeleted(ctx, later); err != nil || purged != 1 {
+		t.Errorf("PurgeDeleted = %d, %v, want 1, nil", purged, err)
+	}
+	if err := server.users.Create(ctx, &model.User{ID: "alice"}); err != nil {
+		t.Errorf("create purged user again: %v", err)
+	}
+}
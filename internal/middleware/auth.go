@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	subjectKey = "auth.subject"
+	adminKey   = "auth.admin"
+	scopeKey   = "auth.scope"
+)
+
+// Claims are the token claims the service relies on. "sub" is the user ID,
+// "admin" allows acting on behalf of any user.
+type Claims struct {
+	Admin bool `json:"admin"`
+	jwt.RegisteredClaims
+}
+
+// AuthMiddleware accepts requests with a valid "Authorization: Bearer" JWT
+// signed with one of keys, or with an "Authorization: ApiKey" key, and stores
+// the user in the context. Issuer and audience of JWTs are checked when not
+// empty.
+func AuthMiddleware(keys *KeySet, issuer, audience string, apiKeys service.APIKeyService) gin.HandlerFunc {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	parser := jwt.NewParser(options...)
+
+	return func(context *gin.Context) {
+		scheme, credentials, _ := strings.Cut(context.GetHeader("Authorization"), " ")
+		switch {
+		case strings.EqualFold(scheme, "Bearer") && credentials != "":
+			authenticateJWT(context, parser, keys, credentials)
+		case strings.EqualFold(scheme, "ApiKey") && credentials != "":
+			authenticateAPIKey(context, apiKeys, credentials)
+		default:
+			logger.Log.Warn("Request without credentials")
+			context.Header("WWW-Authenticate", "Bearer")
+			Abort(context, apperror.Unauthorized("authentication required"))
+		}
+	}
+}
+
+func authenticateJWT(context *gin.Context, parser *jwt.Parser, keys *KeySet, token string) {
+	var claims Claims
+	if _, err := parser.ParseWithClaims(token, &claims, keys.keyFunc); err != nil {
+		logger.Log.Warnf("Invalid token: %v", err)
+		context.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		Abort(context, apperror.Unauthorized("invalid token"))
+		return
+	}
+	if claims.Subject == "" {
+		logger.Log.Warn("Token without a subject")
+		context.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		Abort(context, apperror.Unauthorized("invalid token"))
+		return
+	}
+
+	logger.Log.Infof("Authenticated %s (admin: %t)", claims.Subject, claims.Admin)
+	context.Set(subjectKey, claims.Subject)
+	context.Set(adminKey, claims.Admin)
+	context.Next()
+}
+
+// authenticateAPIKey checks an API key. Admin keys act as admins.
+func authenticateAPIKey(context *gin.Context, apiKeys service.APIKeyService, secret string) {
+	key, err := apiKeys.Authenticate(context.Request.Context(), secret)
+	if err != nil {
+		logger.Log.Warnf("Error checking API key: %v", err)
+		Abort(context, err)
+		return
+	}
+
+	logger.Log.Infof("Authenticated %s with API key %s (%s)", key.UserID, key.Prefix, key.Scope)
+	context.Set(subjectKey, key.UserID)
+	context.Set(adminKey, key.Scope == model.ScopeAdmin)
+	context.Set(scopeKey, key.Scope)
+	context.Next()
+}
+
+// NoAuthMiddleware treats every request as coming from an admin. It is used
+// when authentication is turned off in the config.
+func NoAuthMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Set(adminKey, true)
+		context.Next()
+	}
+}
+
+// Principal returns the authenticated user and whether they are an admin.
+func Principal(context *gin.Context) (subject string, admin bool) {
+	return context.GetString(subjectKey), context.GetBool(adminKey)
+}
+
+// EnforceReadOnly rejects requests that change data when they are made with
+// a read-only API key. readRoutes are routes that only read data despite
+// their method, such as the POST subscription list.
+func EnforceReadOnly(readRoutes ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(readRoutes))
+	for _, route := range readRoutes {
+		allowed[route] = true
+	}
+
+	return func(context *gin.Context) {
+		switch context.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if scope, ok := APIKeyScope(context); ok && scope == model.ScopeReadOnly && !allowed[context.FullPath()] {
+				logger.Log.Warnf("Read-only API key used for %s %s", context.Request.Method, context.FullPath())
+				Abort(context, apperror.Forbidden("the API key is read-only"))
+				return
+			}
+		}
+		context.Next()
+	}
+}
+
+// APIKeyScope returns the scope of the API key the request was authenticated
+// with, if any.
+func APIKeyScope(context *gin.Context) (model.APIKeyScope, bool) {
+	scope, ok := context.Get(scopeKey)
+	if !ok {
+		return "", false
+	}
+	return scope.(model.APIKeyScope), true
+}
+
+// CanActAs reports whether the caller may act on behalf of userID.
+func CanActAs(context *gin.Context, userID string) bool {
+	subject, admin := Principal(context)
+	return admin || (subject != "" && subject == userID)
+}
+
+// RequireAdmin rejects callers without the admin claim.
+func RequireAdmin() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if _, admin := Principal(context); !admin {
+			logger.Log.Warn("Admin access denied")
+			Abort(context, apperror.Forbidden("access denied"))
+			return
+		}
+		context.Next()
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var errNoKey = errors.New("no key for token")
+
+// KeySet holds the keys tokens may be signed with: a shared HS256 secret, an
+// RS256 public key and keys from a JWKS file, looked up by "kid".
+type KeySet struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	keys      map[string]interface{}
+}
+
+// NewKeySet loads the configured keys. Empty arguments are skipped, but at
+// least one key is required.
+func NewKeySet(secret, publicKeyPath, jwksPath string) (*KeySet, error) {
+	keySet := &KeySet{keys: make(map[string]interface{})}
+	if secret != "" {
+		keySet.secret = []byte(secret)
+	}
+	if publicKeyPath != "" {
+		data, err := os.ReadFile(publicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read RS256 public key: %w", err)
+		}
+		if keySet.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return nil, fmt.Errorf("parse RS256 public key %s: %w", publicKeyPath, err)
+		}
+	}
+	if jwksPath != "" {
+		if err := keySet.loadJWKS(jwksPath); err != nil {
+			return nil, err
+		}
+	}
+	if keySet.secret == nil && keySet.publicKey == nil && len(keySet.keys) == 0 {
+		return nil, errors.New("no JWT keys configured")
+	}
+	return keySet, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// loadJWKS reads RSA ("kty": "RSA") and symmetric ("kty": "oct") keys from a
+// JSON Web Key Set file. Keys meant for encryption are skipped.
+func (k *KeySet) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read JWKS: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse JWKS %s: %w", path, err)
+	}
+
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if key.Kid == "" {
+			return fmt.Errorf("JWKS %s: key without kid", path)
+		}
+		switch key.Kty {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("JWKS %s: invalid key %s", path, key.Kid)
+			}
+			k.keys[key.Kid] = secret
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return fmt.Errorf("JWKS %s: invalid key %s", path, key.Kid)
+			}
+			k.keys[key.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		default:
+			return fmt.Errorf("JWKS %s: unsupported key type %q", path, key.Kty)
+		}
+	}
+	return nil
+}
+
+// keyFunc picks the verification key for a token. The key type must match
+// the signing method, so an RSA public key can never be used as an HMAC
+// secret.
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	var key interface{}
+	if kid, _ := token.Header["kid"].(string); kid != "" {
+		key = k.keys[kid]
+	} else if token.Method == jwt.SigningMethodHS256 && k.secret != nil {
+		key = k.secret
+	} else if token.Method == jwt.SigningMethodRS256 && k.publicKey != nil {
+		key = k.publicKey
+	}
+
+	switch key.(type) {
+	case []byte:
+		if token.Method == jwt.SigningMethodHS256 {
+			return key, nil
+		}
+	case *rsa.PublicKey:
+		if token.Method == jwt.SigningMethodRS256 {
+			return key, nil
+		}
+	}
+	return nil, errNoKey
+}
@@ -0,0 +1,333 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "middleware-tests-hs256-secret-0123456789"
+
+// stubAPIKeys authenticates the keys it holds by their secrets.
+type stubAPIKeys struct {
+	service.APIKeyService
+	keys map[string]*model.APIKey
+}
+
+func (s stubAPIKeys) Authenticate(_ context.Context, secret string) (*model.APIKey, error) {
+	if key, ok := s.keys[secret]; ok {
+		return key, nil
+	}
+	return nil, service.ErrInvalidAPIKey
+}
+
+var testAPIKeys = stubAPIKeys{keys: map[string]*model.APIKey{
+	"sa_read":  {UserID: "alice", Prefix: "sa_read", Scope: model.ScopeReadOnly},
+	"sa_write": {UserID: "alice", Prefix: "sa_write", Scope: model.ScopeReadWrite},
+	"sa_admin": {UserID: "root", Prefix: "sa_admin", Scope: model.ScopeAdmin},
+}}
+
+// signHS256 returns claims signed with testSecret.
+func signHS256(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// validClaims are claims of subject that expire in an hour.
+func validClaims(subject string, admin bool) middleware.Claims {
+	return middleware.Claims{
+		Admin: admin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+// serve sends a request with the given Authorization header through router.
+func serve(router *gin.Engine, method, path, authorization string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestEnforceReadOnly(t *testing.T) {
+	keys, err := middleware.NewKeySet(testSecret, "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	router := gin.New()
+	router.Use(middleware.ProblemMiddleware())
+	api := router.Group("/api",
+		middleware.AuthMiddleware(keys, "", "", testAPIKeys),
+		middleware.EnforceReadOnly("/api/subscriptions/:user_id/list"),
+	)
+	ok := func(context *gin.Context) { context.Status(http.StatusOK) }
+	api.GET("/subscriptions/:user_id", ok)
+	api.POST("/subscriptions/:user_id", ok)
+	api.DELETE("/subscriptions/:user_id", ok)
+	api.POST("/subscriptions/:user_id/list", ok)
+
+	token := "Bearer " + signHS256(t, validClaims("alice", false))
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{"read-only key reads", http.MethodGet, "/api/subscriptions/alice", "ApiKey sa_read", http.StatusOK},
+		{"read-only key lists with POST", http.MethodPost, "/api/subscriptions/alice/list", "ApiKey sa_read", http.StatusOK},
+		{"read-only key creates", http.MethodPost, "/api/subscriptions/alice", "ApiKey sa_read", http.StatusForbidden},
+		{"read-only key deletes", http.MethodDelete, "/api/subscriptions/alice", "ApiKey sa_read", http.StatusForbidden},
+		{"read-write key creates", http.MethodPost, "/api/subscriptions/alice", "ApiKey sa_write", http.StatusOK},
+		{"admin key deletes", http.MethodDelete, "/api/subscriptions/alice", "ApiKey sa_admin", http.StatusOK},
+		{"token creates", http.MethodPost, "/api/subscriptions/alice", token, http.StatusOK},
+		{"unknown key", http.MethodGet, "/api/subscriptions/alice", "ApiKey sa_unknown", http.StatusUnauthorized},
+		{"no credentials", http.MethodGet, "/api/subscriptions/alice", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := serve(router, test.method, test.path, test.authorization)
+			if response.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", response.Code, test.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+// writeFile writes data to a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	jwksKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	jwksSecret := []byte("jwks-oct-secret-0123456789abcdef")
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": %q, "e": %q},
+		{"kty": "oct", "kid": "oct-1", "k": %q}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(jwksKey.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(jwksKey.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString(jwksSecret))
+
+	keys, err := middleware.NewKeySet(testSecret, writeFile(t, "public.pem", publicPEM), writeFile(t, "jwks.json", []byte(jwks)))
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	router := gin.New()
+	router.Use(middleware.ProblemMiddleware())
+	router.GET("/me", middleware.AuthMiddleware(keys, "", "", testAPIKeys), func(context *gin.Context) {
+		subject, admin := middleware.Principal(context)
+		context.JSON(http.StatusOK, gin.H{"subject": subject, "admin": admin})
+	})
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.Claims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+	expired := validClaims("alice", false)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	withoutExpiry := validClaims("alice", false)
+	withoutExpiry.ExpiresAt = nil
+
+	tests := []struct {
+		name     string
+		token    string
+		wantBody string // empty when the token must be rejected
+	}{
+		{"HS256", sign(jwt.SigningMethodHS256, "", []byte(testSecret), validClaims("alice", false)), `{"admin":false,"subject":"alice"}`},
+		{"HS256 admin", sign(jwt.SigningMethodHS256, "", []byte(testSecret), validClaims("root", true)), `{"admin":true,"subject":"root"}`},
+		{"RS256", sign(jwt.SigningMethodRS256, "", rsaKey, validClaims("alice", false)), `{"admin":false,"subject":"alice"}`},
+		{"JWKS RSA key", sign(jwt.SigningMethodRS256, "rsa-1", jwksKey, validClaims("bob", false)), `{"admin":false,"subject":"bob"}`},
+		{"JWKS oct key", sign(jwt.SigningMethodHS256, "oct-1", jwksSecret, validClaims("bob", true)), `{"admin":true,"subject":"bob"}`},
+		{"expired", sign(jwt.SigningMethodHS256, "", []byte(testSecret), expired), ""},
+		{"without expiry", sign(jwt.SigningMethodHS256, "", []byte(testSecret), withoutExpiry), ""},
+		{"without subject", sign(jwt.SigningMethodHS256, "", []byte(testSecret), validClaims("", true)), ""},
+		{"wrong secret", sign(jwt.SigningMethodHS256, "", []byte("another-secret-0123456789abcdef"), validClaims("alice", false)), ""},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "rsa-2", jwksKey, validClaims("bob", false)), ""},
+		{"kid of a key of another type", sign(jwt.SigningMethodHS256, "rsa-1", jwksSecret, validClaims("bob", false)), ""},
+		{"HS256 signed with the RSA public key", sign(jwt.SigningMethodHS256, "", publicPEM, validClaims("alice", true)), ""},
+		{"HS384", sign(jwt.SigningMethodHS384, "", []byte(testSecret), validClaims("alice", false)), ""},
+		{"alg none", sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims("alice", true)), ""},
+		{"garbage", "not-a-token", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := serve(router, http.MethodGet, "/me", "Bearer "+test.token)
+			if test.wantBody == "" {
+				if response.Code != http.StatusUnauthorized {
+					t.Errorf("status = %d, want %d: %s", response.Code, http.StatusUnauthorized, response.Body)
+				}
+				if got := response.Header().Get("WWW-Authenticate"); got != `Bearer error="invalid_token"` {
+					t.Errorf("WWW-Authenticate = %q", got)
+				}
+				return
+			}
+			if response.Code != http.StatusOK || response.Body.String() != test.wantBody {
+				t.Errorf("response = %d %s, want %d %s", response.Code, response.Body, http.StatusOK, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestAuthenticateJWTIssuerAndAudience(t *testing.T) {
+	keys, err := middleware.NewKeySet(testSecret, "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	router := gin.New()
+	router.Use(middleware.ProblemMiddleware())
+	router.GET("/me", middleware.AuthMiddleware(keys, "https://issuer.example", "subscriptions", testAPIKeys), func(context *gin.Context) {
+		context.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		issuer     string
+		audience   []string
+		wantStatus int
+	}{
+		{"matching", "https://issuer.example", []string{"billing", "subscriptions"}, http.StatusOK},
+		{"other issuer", "https://other.example", []string{"subscriptions"}, http.StatusUnauthorized},
+		{"other audience", "https://issuer.example", []string{"billing"}, http.StatusUnauthorized},
+		{"no issuer", "", []string{"subscriptions"}, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := validClaims("alice", false)
+			claims.Issuer = test.issuer
+			claims.Audience = test.audience
+			response := serve(router, http.MethodGet, "/me", "Bearer "+signHS256(t, claims))
+			if response.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", response.Code, test.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+func TestCanActAs(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		userID        string
+		want          bool
+	}{
+		{"own user", "ApiKey sa_write", "alice", true},
+		{"other user", "ApiKey sa_write", "bob", false},
+		{"admin for other user", "ApiKey sa_admin", "bob", true},
+	}
+	keys, err := middleware.NewKeySet(testSecret, "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got bool
+			router := gin.New()
+			router.GET("/users/:id", middleware.AuthMiddleware(keys, "", "", testAPIKeys), func(context *gin.Context) {
+				got = middleware.CanActAs(context, context.Param("id"))
+			})
+			serve(router, http.MethodGet, "/users/"+test.userID, test.authorization)
+			if got != test.want {
+				t.Errorf("CanActAs(%s) = %t, want %t", test.userID, got, test.want)
+			}
+		})
+	}
+
+	t.Run("no subject", func(t *testing.T) {
+		got := true
+		router := gin.New()
+		router.GET("/me", func(context *gin.Context) {
+			got = middleware.CanActAs(context, "")
+		})
+		serve(router, http.MethodGet, "/me", "")
+		if got {
+			t.Error("CanActAs of an empty user without a subject = true, want false")
+		}
+	})
+}
+
+func TestRequireAdmin(t *testing.T) {
+	keys, err := middleware.NewKeySet(testSecret, "", "")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	router := gin.New()
+	router.Use(middleware.ProblemMiddleware())
+	router.GET("/admin", middleware.AuthMiddleware(keys, "", "", testAPIKeys), middleware.RequireAdmin(), func(context *gin.Context) {
+		context.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{"admin token", "Bearer " + signHS256(t, validClaims("root", true)), http.StatusOK},
+		{"admin key", "ApiKey sa_admin", http.StatusOK},
+		{"user token", "Bearer " + signHS256(t, validClaims("alice", false)), http.StatusForbidden},
+		{"read-write key", "ApiKey sa_write", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := serve(router, http.MethodGet, "/admin", test.authorization)
+			if response.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", response.Code, test.wantStatus, response.Body)
+			}
+		})
+	}
+}
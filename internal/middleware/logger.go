@@ -28,11 +28,12 @@ func LoggerMiddleware() gin.HandlerFunc {
 		}
 
 		logger.Log.WithFields(map[string]interface{}{
-			"status":   statusCode,
-			"method":   method,
-			"path":     path,
-			"duration": duration,
-			"clientIP": clientIP,
+			"status":    statusCode,
+			"method":    method,
+			"path":      path,
+			"duration":  duration,
+			"clientIP":  clientIP,
+			"requestID": RequestID(context),
 		}).Info("completed request")
 	}
 }
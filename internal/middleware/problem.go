@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type      string            `json:"type" example:"about:blank"`
+	Title     string            `json:"title" example:"Not Found"`
+	Status    int               `json:"status" example:"404"`
+	Detail    string            `json:"detail,omitempty" example:"subscription not found"`
+	Instance  string            `json:"instance,omitempty" example:"/api/subscriptions/60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// Abort stops the request with err. ProblemMiddleware writes the response.
+func Abort(context *gin.Context, err error) {
+	_ = context.Error(err)
+	context.Abort()
+}
+
+// ProblemMiddleware answers requests that failed with an error, recorded by
+// Abort, with an application/problem+json body. The status comes from the
+// kind of the error; errors without one are internal. The detail is the
+// message of the error, the full error is only logged.
+func ProblemMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Next()
+
+		if len(context.Errors) == 0 {
+			return
+		}
+		err := context.Errors.Last().Err
+		if context.Writer.Written() {
+			logger.Log.Errorf("Response already sent, dropping error: %v", err)
+			return
+		}
+
+		kind := apperror.KindOf(err)
+		problem := Problem{
+			Type:      "about:blank",
+			Status:    kind.Status(),
+			Title:     statusText(kind.Status()),
+			Instance:  context.Request.URL.Path,
+			RequestID: RequestID(context),
+		}
+		// Only messages of application errors reach clients; their causes
+		// may name tables, constraints or drivers.
+		problem.Detail = "internal server error"
+		if appErr, ok := apperror.As(err); ok {
+			problem.Detail = appErr.Message
+			problem.Errors = appErr.Fields
+		}
+		switch {
+		case kind == apperror.KindCanceled:
+			// The client is gone and won't read the response.
+			logger.Log.Infof("Request canceled by the client: %v", err)
+		case problem.Status >= http.StatusInternalServerError:
+			logger.Log.Errorf("Request failed: %v", err)
+		default:
+			logger.Log.Warnf("Request rejected: %v", err)
+		}
+
+		context.Header("Content-Type", problemContentType)
+		context.JSON(problem.Status, problem)
+	}
+}
+
+func statusText(status int) string {
+	if status == apperror.StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
@@ -0,0 +1,121 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestProblemMiddleware(t *testing.T) {
+	// dbErr names a table and a constraint, which must not reach clients.
+	dbErr := &pgconn.PgError{
+		Code:           "23503",
+		Message:        `insert or update on table "subscriptions" violates foreign key constraint "fk_subscriptions_user"`,
+		ConstraintName: "fk_subscriptions_user",
+	}
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+		wantFields map[string]string
+	}{
+		{
+			name:       "conflict wrapping a database error",
+			err:        fmt.Errorf("%w (%s): %w", repository.ErrConflict, dbErr.ConstraintName, dbErr),
+			wantStatus: http.StatusConflict,
+			wantDetail: "record conflicts with existing data",
+		},
+		{
+			name:       "unavailable wrapping a database error",
+			err:        fmt.Errorf("%w: %w", repository.ErrUnavailable, errors.New("dial tcp 10.0.0.5:5432: connection refused")),
+			wantStatus: http.StatusServiceUnavailable,
+			wantDetail: "database is unavailable",
+		},
+		{
+			name:       "database error",
+			err:        fmt.Errorf("create subscription: %w", dbErr),
+			wantStatus: http.StatusInternalServerError,
+			wantDetail: "internal server error",
+		},
+		{
+			name:       "internal error with a cause",
+			err:        apperror.Internal("could not load rates", dbErr),
+			wantStatus: http.StatusInternalServerError,
+			wantDetail: "could not load rates",
+		},
+		{
+			name:       "detail added to an application error",
+			err:        apperror.NotFound("subscription not found").Detailf("%s", "2f1c"),
+			wantStatus: http.StatusNotFound,
+			wantDetail: "subscription not found: 2f1c",
+		},
+		{
+			name:       "validation error",
+			err:        apperror.Validation("validation failed", map[string]string{"price": "must not be negative"}),
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "validation failed",
+			wantFields: map[string]string{"price": "must not be negative"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(middleware.ProblemMiddleware())
+			router.GET("/fail", func(context *gin.Context) {
+				middleware.Abort(context, test.err)
+			})
+
+			response := serve(router, http.MethodGet, "/fail", "")
+			if response.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", response.Code, test.wantStatus)
+			}
+			if got := response.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/problem+json") {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			body := response.Body.String()
+			for _, leak := range []string{"subscriptions", "fk_subscriptions_user", "10.0.0.5"} {
+				if strings.Contains(body, leak) {
+					t.Errorf("body %s shows %q", body, leak)
+				}
+			}
+
+			var problem middleware.Problem
+			if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Status != test.wantStatus || problem.Title != http.StatusText(test.wantStatus) ||
+				problem.Detail != test.wantDetail || problem.Instance != "/fail" {
+				t.Errorf("problem = %+v, want status %d with detail %q", problem, test.wantStatus, test.wantDetail)
+			}
+			if len(problem.Errors) != len(test.wantFields) || problem.Errors["price"] != test.wantFields["price"] {
+				t.Errorf("errors = %v, want %v", problem.Errors, test.wantFields)
+			}
+		})
+	}
+}
+
+func TestProblemMiddlewareKeepsSentResponse(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.ProblemMiddleware())
+	router.GET("/sent", func(context *gin.Context) {
+		context.String(http.StatusOK, "partial")
+		middleware.Abort(context, errors.New("failed after writing"))
+	})
+
+	response := serve(router, http.MethodGet, "/sent", "")
+	if response.Code != http.StatusOK || response.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the sent one", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"subscription-aggregator/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+	maxRequestIDLen = 64
+)
+
+// RequestIDMiddleware keeps the X-Request-ID of the request, or generates
+// one, and echoes it in the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = uuid.NewString()
+		}
+		context.Set(requestIDKey, id)
+		context.Header(RequestIDHeader, id)
+		context.Next()
+	}
+}
+
+// RequestID returns the ID of the request.
+func RequestID(context *gin.Context) string {
+	return context.GetString(requestIDKey)
+}
+
+// Actor returns who makes the request, for the audit log.
+func Actor(context *gin.Context) model.Actor {
+	subject, _ := Principal(context)
+	return model.Actor{UserID: subject, RequestID: RequestID(context)}
+}
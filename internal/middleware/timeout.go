@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	stdcontext "context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutMiddleware bounds the database queries of a request: the request
+// context, which the handlers pass down to the repositories, is cancelled
+// after timeout. A zero timeout leaves requests unbounded.
+func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if timeout <= 0 {
+			context.Next()
+			return
+		}
+		ctx, cancel := stdcontext.WithTimeout(context.Request.Context(), timeout)
+		defer cancel()
+		context.Request = context.Request.WithContext(ctx)
+		context.Next()
+	}
+}
@@ -0,0 +1,101 @@
+//go:build cgo
+
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/middleware"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newUserRouter serves GET /users/:id from a SQLite database behind the
+// timeout and problem middleware. The handler waits for the request context
+// to end before querying, as a slow query would.
+func newUserRouter(t *testing.T, timeout time.Duration) *gin.Engine {
+	t.Helper()
+	db := database.InitSQLite(filepath.Join(t.TempDir(), "timeout.db"))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrations.CreateSQLiteSchema(db); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	users := repository.NewUserRepository(db)
+
+	router := gin.New()
+	router.Use(middleware.ProblemMiddleware())
+	router.GET("/users/:id", middleware.TimeoutMiddleware(timeout), func(context *gin.Context) {
+		ctx := context.Request.Context()
+		<-ctx.Done()
+		user, err := users.GetByID(ctx, context.Param("id"))
+		if err != nil {
+			middleware.Abort(context, err)
+			return
+		}
+		context.JSON(http.StatusOK, user)
+	})
+	return router
+}
+
+func TestTimeoutAndCancellation(t *testing.T) {
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		cancel     bool
+		wantStatus int
+		wantTitle  string
+	}{
+		{
+			name:       "expired deadline",
+			timeout:    time.Millisecond,
+			wantStatus: http.StatusGatewayTimeout,
+			wantTitle:  "Gateway Timeout",
+		},
+		{
+			name:       "canceled by the client",
+			timeout:    time.Minute,
+			cancel:     true,
+			wantStatus: apperror.StatusClientClosedRequest,
+			wantTitle:  "Client Closed Request",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := newUserRouter(t, test.timeout)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.cancel {
+				cancel()
+			}
+			request := httptest.NewRequest(http.MethodGet, "/users/alice", nil).WithContext(ctx)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			if response.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", response.Code, test.wantStatus, response.Body)
+			}
+			var problem middleware.Problem
+			if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Status != test.wantStatus || problem.Title != test.wantTitle {
+				t.Errorf("problem = %+v, want status %d titled %q", problem, test.wantStatus, test.wantTitle)
+			}
+		})
+	}
+}
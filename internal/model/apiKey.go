@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyScope string
+
+const (
+	ScopeReadOnly  APIKeyScope = "read-only"
+	ScopeReadWrite APIKeyScope = "read-write"
+	ScopeAdmin     APIKeyScope = "admin"
+)
+
+// APIKey is a credential for machine clients. Only the SHA-256 hash of the
+// secret is stored; Prefix is kept to tell keys apart.
+type APIKey struct {
+	ID         uuid.UUID   `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID     string      `gorm:"type:varchar(255);not null;index" json:"user_id"`
+	Name       string      `gorm:"type:varchar(255)" json:"name"`
+	Prefix     string      `gorm:"type:varchar(16);not null" json:"prefix"`
+	Hash       string      `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	Scope      APIKeyScope `gorm:"type:varchar(16);not null" json:"scope" swaggertype:"string" enums:"read-only,read-write,admin"`
+	CreatedAt  time.Time   `json:"created_at"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty"`
+	User       *User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-" swaggerignore:"true"`
+}
+
+// Valid reports whether s is a known scope.
+func (s APIKeyScope) Valid() bool {
+	switch s {
+	case ScopeReadOnly, ScopeReadWrite, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// IssuedAPIKey is returned once when a key is issued; the secret can't be
+// recovered later.
+type IssuedAPIKey struct {
+	APIKey
+	Secret string `json:"secret"`
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPrice   AuditAction = "price"
+	AuditSplit   AuditAction = "split"
+)
+
+// Actor is who makes a change, recorded in the audit log.
+type Actor struct {
+	UserID    string
+	RequestID string
+}
+
+// FieldChange is the value of a field before and after a change; a missing
+// side means the field was added or removed.
+type FieldChange struct {
+	Before json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After  json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+}
+
+// AuditChanges maps the JSON names of changed subscription fields to their
+// values. It is stored as jsonb.
+type AuditChanges map[string]FieldChange
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("cannot scan %T into AuditChanges", value)
+	}
+}
+
+// AuditEntry records one change of a subscription. Entries are only ever
+// appended and outlive the subscription they describe.
+type AuditEntry struct {
+	ID             uint64       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubscriptionID uuid.UUID    `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	UserID         string       `gorm:"type:varchar(255);not null;index" json:"user_id"`
+	Action         AuditAction  `gorm:"type:varchar(16);not null" json:"action" swaggertype:"string" enums:"create,update,delete,restore,price,split"`
+	Actor          string       `gorm:"type:varchar(255);index" json:"actor,omitempty"`
+	RequestID      string       `gorm:"type:varchar(64)" json:"request_id,omitempty"`
+	Changes        AuditChanges `gorm:"type:jsonb;not null" json:"changes"`
+	CreatedAt      time.Time    `gorm:"not null;index" json:"created_at"`
+}
+
+// AuditFilter selects audit entries of a subscription or those concerning a
+// user, i.e. changes of their subscriptions and changes they made.
+type AuditFilter struct {
+	SubscriptionID *uuid.UUID
+	UserID         string
+}
+
+type AuditPage struct {
+	Items    []AuditEntry `json:"items"`
+	Total    int64        `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
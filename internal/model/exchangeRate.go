@@ -0,0 +1,31 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the base currency of the exchange-rate table. Every rate
+// is the price of one unit of a currency expressed in DefaultCurrency.
+const DefaultCurrency = "RUB"
+
+type ExchangeRate struct {
+	Currency  string    `gorm:"type:varchar(3);primaryKey" json:"currency"`
+	Rate      float64   `gorm:"type:numeric(20,8);not null" json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and reports whether it looks
+// like one.
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return code, true
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GroupRole string
+
+const (
+	RoleViewer GroupRole = "viewer"
+	RoleEditor GroupRole = "editor"
+	RoleOwner  GroupRole = "owner"
+)
+
+var roleRanks = map[GroupRole]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Valid reports whether r is a known role.
+func (r GroupRole) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether r grants at least the required role: owners can do
+// everything editors can, editors everything viewers can.
+func (r GroupRole) Allows(required GroupRole) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// Group is a household or team that shares subscriptions.
+type Group struct {
+	ID        uuid.UUID     `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	Name      string        `gorm:"type:varchar(255);not null" json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+	Members   []GroupMember `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"members,omitempty"`
+}
+
+type GroupMember struct {
+	GroupID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	UserID    string    `gorm:"type:varchar(255);primaryKey;index" json:"user_id"`
+	Role      GroupRole `gorm:"type:varchar(16);not null" json:"role" swaggertype:"string" enums:"owner,editor,viewer"`
+	CreatedAt time.Time `json:"created_at"`
+	User      *User     `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-" swaggerignore:"true"`
+}
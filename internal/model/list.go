@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionFilter narrows down subscription lists. Empty fields don't
+// filter anything.
+type SubscriptionFilter struct {
+	UserID              string
+	GroupID             *uuid.UUID
+	ParticipantID       string // pays for or has a split share of the subscription
+	ServiceName         string
+	ServiceNameContains string
+	PriceMin            *Money
+	PriceMax            *Money
+	ActiveAt            *time.Time
+	StartedFrom         *time.Time
+	StartedTo           *time.Time
+	EndedFrom           *time.Time
+	EndedTo             *time.Time
+	SortBy              string
+	SortDesc            bool
+	IncludeDeleted      bool
+}
+
+// SortableFields lists the values accepted by SubscriptionFilter.SortBy.
+var SortableFields = []string{"service_name", "price", "currency", "billing_period", "start_date", "end_date"}
+
+type SubscriptionPage struct {
+	Items    []Subscription `json:"items"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+// Cursor is a position in a subscription list ordered by start date and ID.
+// Backward cursors point at the items before the position.
+type Cursor struct {
+	StartDate time.Time `json:"s"`
+	ID        uuid.UUID `json:"i"`
+	Desc      bool      `json:"d,omitempty"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+type CursorPage struct {
+	Items []Subscription
+	Next  *Cursor
+	Prev  *Cursor
+}
+
+type SubscriptionCursorPage struct {
+	Items      []Subscription `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+	PageSize   int            `json:"page_size"`
+}
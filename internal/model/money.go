@@ -0,0 +1,141 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"subscription-aggregator/internal/apperror"
+)
+
+// MinorUnits is the number of decimal places every currency is stored with.
+const MinorUnits = 2
+
+const minorFactor = 100
+
+var (
+	ErrMoneyOverflow = apperror.New(apperror.KindUnprocessable, "money overflow")
+	ErrInvalidMoney  = apperror.Validation("invalid money amount", nil)
+)
+
+// Money is an amount in minor units (kopecks, cents). It is serialized to JSON
+// as a decimal string such as "299.90".
+type Money int64
+
+// ParseMoney parses a decimal amount with at most MinorUnits fractional
+// digits, e.g. "299", "299.9" or "299.90".
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || (hasFrac && frac == "") || len(frac) > MinorUnits || !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidMoney.Detailf("%q", s)
+	}
+	frac += strings.Repeat("0", MinorUnits-len(frac))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, ErrMoneyOverflow.Detailf("%q", s)
+	}
+	minor, _ := strconv.ParseInt(frac, 10, 64)
+
+	m, err := Money(units).Mul(minorFactor)
+	if err != nil {
+		return 0, err
+	}
+	if m, err = m.Add(Money(minor)); err != nil {
+		return 0, err
+	}
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Add returns m+o or ErrMoneyOverflow.
+func (m Money) Add(o Money) (Money, error) {
+	sum := m + o
+	if (o > 0 && sum < m) || (o < 0 && sum > m) {
+		return 0, ErrMoneyOverflow
+	}
+	return sum, nil
+}
+
+// Mul returns m*n or ErrMoneyOverflow.
+func (m Money) Mul(n int64) (Money, error) {
+	if m == 0 || n == 0 {
+		return 0, nil
+	}
+	product := int64(m) * n
+	if product/n != int64(m) || (int64(m) == -1 && n == math.MinInt64) || (n == -1 && int64(m) == math.MinInt64) {
+		return 0, ErrMoneyOverflow
+	}
+	return Money(product), nil
+}
+
+// MulRat returns m*r rounded to the nearest minor unit, halves away from zero.
+// It is used for every fractional operation (currency conversion, proration)
+// so that rounding is the same everywhere.
+func (m Money) MulRat(r *big.Rat) (Money, error) {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(m)), r)
+
+	num := new(big.Int).Abs(product.Num())
+	den := product.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if product.Sign() < 0 {
+		quo.Neg(quo)
+	}
+
+	if !quo.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return Money(quo.Int64()), nil
+}
+
+func (m Money) String() string {
+	sign := ""
+	abs := uint64(m)
+	if m < 0 {
+		sign = "-"
+		abs = uint64(-(m + 1)) + 1
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/minorFactor, MinorUnits, abs%minorFactor)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both decimal strings and plain JSON numbers.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return ErrInvalidMoney.Detailf("%s", data)
+		}
+		s = n.String()
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
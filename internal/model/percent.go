@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+	"subscription-aggregator/internal/apperror"
+)
+
+// PercentScale is 100% in basis points.
+const PercentScale = 10000
+
+var ErrInvalidPercent = apperror.Validation("invalid percent", nil)
+
+// Percent is a percentage in basis points, hundredths of a percent. It is
+// serialized to JSON as a decimal number such as 25.5.
+type Percent int64
+
+// ParsePercent parses a decimal percentage with at most two fractional
+// digits, e.g. "25", "25.5" or "33.33".
+func ParsePercent(s string) (Percent, error) {
+	// Basis points are written like money in minor units.
+	points, err := ParseMoney(s)
+	if err != nil {
+		return 0, ErrInvalidPercent.Detailf("%q", s)
+	}
+	return Percent(points), nil
+}
+
+// Rat returns p as a fraction of one, 2550 basis points as 51/200.
+func (p Percent) Rat() *big.Rat {
+	return big.NewRat(int64(p), PercentScale)
+}
+
+func (p Percent) String() string {
+	return Money(p).String()
+}
+
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and decimal strings.
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return ErrInvalidPercent.Detailf("%s", data)
+		}
+		s = n.String()
+	}
+	parsed, err := ParsePercent(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
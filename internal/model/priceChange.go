@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PriceChange is a price that applies to a subscription from EffectiveFrom
+// until the next change. The first change of every subscription is its
+// starting price.
+type PriceChange struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_price_changes_subscription_date" json:"subscription_id"`
+	Price          Money     `gorm:"column:price_minor;not null" json:"price" swaggertype:"string" example:"299.90"`
+	EffectiveFrom  time.Time `gorm:"not null;uniqueIndex:idx_price_changes_subscription_date" json:"effective_from"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+type SplitMethod string
+
+const (
+	SplitNone       SplitMethod = ""
+	SplitEqual      SplitMethod = "equal"
+	SplitPercentage SplitMethod = "percentage"
+	SplitFixed      SplitMethod = "fixed"
+)
+
+// SplitShare is the part of every charge of a shared subscription that a user
+// pays back to its payer (Subscription.UserID): nothing extra for an equal
+// split, Percent of the charge or a fixed Amount per charge. Whatever the
+// shares don't cover stays with the payer.
+type SplitShare struct {
+	SubscriptionID uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	UserID         string    `gorm:"type:varchar(255);primaryKey;index" json:"user_id" binding:"required,max=255"`
+	Percent        *Percent  `gorm:"column:percent_bp" json:"percent,omitempty" swaggertype:"number" example:"25"`
+	Amount         *Money    `gorm:"column:amount_minor" json:"amount,omitempty" swaggertype:"string" example:"99.90"`
+	User           *User     `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-" swaggerignore:"true"`
+}
+
+// SubscriptionCharges is what a subscription was charged over a period.
+type SubscriptionCharges struct {
+	SubscriptionID uuid.UUID
+	UserID         string
+	SplitMethod    SplitMethod
+	Currency       string
+	Total          Money
+	Charges        int64
+}
+
+// Debt is money one user owes another for their shares of subscriptions.
+type Debt struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount Money  `json:"amount" swaggertype:"string" example:"149.95"`
+}
+
+type Settlement struct {
+	Currency string `json:"currency"`
+	Debts    []Debt `json:"debts"`
+}
@@ -4,13 +4,78 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BillingPeriod string
+
+const (
+	BillingWeekly    BillingPeriod = "weekly"
+	BillingMonthly   BillingPeriod = "monthly"
+	BillingQuarterly BillingPeriod = "quarterly"
+	BillingYearly    BillingPeriod = "yearly"
+	BillingCustom    BillingPeriod = "custom"
 )
 
 type Subscription struct {
-	UserID      string     `gorm:"type:varchar(255);index" json:"user_id"`
-	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
-	ServiceName string     `gorm:"index" json:"service_name"`
-	Price       uint       `gorm:"index" json:"price"`
-	StartDate   time.Time  `gorm:"index" json:"start_date"`
-	EndDate     *time.Time `gorm:"index" json:"end_date,omitempty"`
+	UserID            string         `gorm:"type:varchar(255);index" json:"user_id"`
+	GroupID           *uuid.UUID     `gorm:"type:uuid;index" json:"group_id,omitempty"`
+	ID                uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	ServiceName       string         `gorm:"index" json:"service_name"`
+	Price             Money          `gorm:"column:price_minor;index" json:"price" swaggertype:"string" example:"299.90"`
+	Currency          string         `gorm:"type:varchar(3);not null;default:RUB" json:"currency"`
+	BillingPeriod     BillingPeriod  `gorm:"type:varchar(16);not null;default:monthly" json:"billing_period"`
+	BillingPeriodDays uint           `gorm:"not null;default:0" json:"billing_period_days,omitempty"`
+	StartDate         time.Time      `gorm:"index" json:"start_date"`
+	EndDate           *time.Time     `gorm:"index" json:"end_date,omitempty"`
+	Version           uint           `gorm:"not null;default:1" json:"version"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string" format:"date-time"`
+	SplitMethod       SplitMethod    `gorm:"type:varchar(16);not null;default:''" json:"split_method,omitempty" swaggertype:"string" enums:"equal,percentage,fixed"`
+	Shares            []SplitShare   `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE" json:"shares,omitempty"`
+	PriceChanges      []PriceChange  `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE" json:"price_changes,omitempty"`
+	User              *User          `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-" swaggerignore:"true"`
+	Group             *Group         `gorm:"foreignKey:GroupID;constraint:OnDelete:SET NULL" json:"-" swaggerignore:"true"`
+}
+
+// PriceAt returns the price in effect at t. PriceChanges must be sorted by
+// EffectiveFrom; without an applicable change the stored price is used.
+func (s *Subscription) PriceAt(t time.Time) Money {
+	price := s.Price
+	for _, change := range s.PriceChanges {
+		if change.EffectiveFrom.After(t) {
+			break
+		}
+		price = change.Price
+	}
+	return price
+}
+
+// Valid reports whether p is a known billing period. A custom period also
+// needs a positive number of days.
+func (p BillingPeriod) Valid(days uint) bool {
+	switch p {
+	case BillingWeekly, BillingMonthly, BillingQuarterly, BillingYearly:
+		return true
+	case BillingCustom:
+		return days > 0
+	}
+	return false
+}
+
+// ChargeDate returns the date of the n-th charge (starting from zero) of a
+// subscription that started at start. Dates are always derived from start so
+// that month-end anniversaries don't drift.
+func (p BillingPeriod) ChargeDate(start time.Time, days uint, n int) time.Time {
+	switch p {
+	case BillingWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case BillingQuarterly:
+		return start.AddDate(0, 3*n, 0)
+	case BillingYearly:
+		return start.AddDate(n, 0, 0)
+	case BillingCustom:
+		return start.AddDate(0, 0, int(days)*n)
+	default:
+		return start.AddDate(0, n, 0)
+	}
 }
@@ -0,0 +1,27 @@
+package model
+
+type Total struct {
+	Sum       Money            `json:"sum" swaggertype:"string" example:"1499.50"`
+	Currency  string           `json:"currency"`
+	Breakdown map[string]Money `json:"breakdown" swaggertype:"object,string"`
+}
+
+// ServiceMonthTotal is the amount charged for one service in one calendar
+// month, in the subscription's own currency.
+type ServiceMonthTotal struct {
+	Month       string
+	ServiceName string
+	Currency    string
+	Total       Money
+}
+
+type MonthlySpending struct {
+	Month    string           `json:"month" example:"2025-01"`
+	Total    Money            `json:"total" swaggertype:"string" example:"1499.50"`
+	Services map[string]Money `json:"services" swaggertype:"object,string"`
+}
+
+type SpendingSeries struct {
+	Currency string            `json:"currency"`
+	Months   []MonthlySpending `json:"months"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User is someone subscriptions belong to. Deleted users are kept, hidden,
+// until the retention purge removes them with the last of their
+// subscriptions.
+type User struct {
+	ID        string         `gorm:"type:varchar(255);primaryKey" json:"id"`
+	Name      string         `gorm:"type:varchar(255)" json:"name,omitempty"`
+	Email     string         `gorm:"type:varchar(255)" json:"email,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type UserPage struct {
+	Items    []User `json:"items"`
+	Total    int64  `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+}
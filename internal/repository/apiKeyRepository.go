@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	GetByUser(ctx context.Context, userID string) ([]model.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID, at time.Time) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at, staleBefore time.Time) error
+}
+
+type apiKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	logger.Log.Info("Creating new APIKeyRepository")
+	return &apiKeyRepo{db: db}
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, key *model.APIKey) error {
+	logger.Log.Infof("Creating API key %s for user %s", key.Prefix, key.UserID)
+	err := r.db.WithContext(ctx).Create(key).Error
+	if err != nil {
+		logger.Log.Errorf("Error creating API key: %v", err)
+	} else {
+		logger.Log.Infof("API key created successfully: %s", key.ID)
+	}
+	return translateError(err)
+}
+
+func (r *apiKeyRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error) {
+	logger.Log.Infof("Getting API key by ID: %s", id)
+	var key model.APIKey
+	err := r.db.WithContext(ctx).First(&key, "id = ?", id).Error
+	if err != nil {
+		logger.Log.Errorf("API key %s not found: %v", id, err)
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepo) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.WithContext(ctx).First(&key, "hash = ?", hash).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepo) GetByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	logger.Log.Infof("Getting API keys of user %s", userID)
+	var keys []model.APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&keys).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving API keys: %v", err)
+		return nil, translateError(err)
+	}
+	logger.Log.Infof("Retrieved %d API keys", len(keys))
+	return keys, nil
+}
+
+// Revoke marks the key as revoked. Revoking a revoked key keeps the original
+// time.
+func (r *apiKeyRepo) Revoke(ctx context.Context, id uuid.UUID, at time.Time) error {
+	logger.Log.Infof("Revoking API key %s", id)
+	err := r.db.WithContext(ctx).Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+	if err != nil {
+		logger.Log.Errorf("Error revoking API key %s: %v", id, err)
+	}
+	return translateError(err)
+}
+
+// TouchLastUsed records a use of the key unless one was already recorded
+// after staleBefore, so that busy clients don't cause a write per request.
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, id uuid.UUID, at, staleBefore time.Time) error {
+	err := r.db.WithContext(ctx).Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, staleBefore).
+		Update("last_used_at", at).Error
+	if err != nil {
+		logger.Log.Errorf("Error updating last use of API key %s: %v", id, err)
+	}
+	return translateError(err)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository stores the audit log. There is deliberately no way to
+// change or remove entries.
+type AuditRepository interface {
+	Create(ctx context.Context, entry *model.AuditEntry) error
+	GetList(ctx context.Context, filter model.AuditFilter, offset, limit int) ([]model.AuditEntry, int64, error)
+}
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	logger.Log.Info("Creating new AuditRepository")
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Create(ctx context.Context, entry *model.AuditEntry) error {
+	logger.Log.Infof("Recording %s of subscription %s by %q", entry.Action, entry.SubscriptionID, entry.Actor)
+	err := r.db.WithContext(ctx).Create(entry).Error
+	if err != nil {
+		logger.Log.Errorf("Error recording audit entry: %v", err)
+	}
+	return translateError(err)
+}
+
+// GetList returns the entries matching filter, newest first.
+func (r *auditRepo) GetList(ctx context.Context, filter model.AuditFilter, offset, limit int) ([]model.AuditEntry, int64, error) {
+	logger.Log.Infof("Getting audit entries for %+v with offset %d, limit %d", filter, offset, limit)
+	query := r.db.WithContext(ctx).Model(&model.AuditEntry{})
+	if filter.SubscriptionID != nil {
+		query = query.Where("subscription_id = ?", *filter.SubscriptionID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ? OR actor = ?", filter.UserID, filter.UserID)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		logger.Log.Errorf("Error counting audit entries: %v", err)
+		return nil, 0, translateError(err)
+	}
+
+	var entries []model.AuditEntry
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving audit entries: %v", err)
+		return nil, 0, translateError(err)
+	}
+	logger.Log.Infof("Retrieved %d of %d audit entries", len(entries), total)
+	return entries, total, nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"subscription-aggregator/internal/model"
+	"time"
+)
+
+// The functions below calculate charges in Go for the repositories that can't
+// do it in SQL. They follow chargesJoinSQL exactly, so every implementation
+// returns the same totals.
+
+// activeBetween reports whether sub is active at some point between from and
+// to, both optional.
+func activeBetween(sub *model.Subscription, from, to *time.Time) bool {
+	if to != nil && sub.StartDate.After(*to) {
+		return false
+	}
+	return from == nil || sub.EndDate == nil || !sub.EndDate.Before(*from)
+}
+
+// chargeWindow clamps sub to the period between from and to, both optional.
+// A subscription without an end date runs until now.
+func chargeWindow(sub *model.Subscription, from, to *time.Time, now time.Time) (start, end time.Time) {
+	start, end = sub.StartDate, now
+	if sub.EndDate != nil {
+		end = *sub.EndDate
+	}
+	if from != nil && from.After(start) {
+		start = *from
+	}
+	if to != nil && to.Before(end) {
+		end = *to
+	}
+	return start.UTC(), end.UTC()
+}
+
+// billingStep returns the billing period of sub either in days (weekly,
+// custom) or in months (quarterly, yearly). Monthly and unknown periods have
+// neither.
+func billingStep(sub *model.Subscription) (days, months int) {
+	switch {
+	case sub.BillingPeriod == model.BillingWeekly:
+		return 7, 0
+	case sub.BillingPeriod == model.BillingCustom && sub.BillingPeriodDays > 0:
+		return int(sub.BillingPeriodDays), 0
+	case sub.BillingPeriod == model.BillingQuarterly:
+		return 0, 3
+	case sub.BillingPeriod == model.BillingYearly:
+		return 0, 12
+	}
+	return 0, 0
+}
+
+// chargeDates returns the dates sub is charged on between start and end.
+func chargeDates(sub *model.Subscription, start, end time.Time) []time.Time {
+	if end.Before(start) {
+		return nil
+	}
+	anchor := sub.StartDate.UTC()
+	stepDays, stepMonths := billingStep(sub)
+
+	var dates []time.Time
+	switch {
+	case stepDays > 0:
+		step := float64(86400 * stepDays)
+		first := int(math.Ceil(start.Sub(anchor).Seconds() / step))
+		last := int(math.Floor(end.Sub(anchor).Seconds() / step))
+		for n := first; n <= last; n++ {
+			dates = append(dates, sub.BillingPeriod.ChargeDate(anchor, uint(stepDays), n))
+		}
+	case stepMonths > 0:
+		last := monthsBetween(anchor, end) / stepMonths
+		for n := 0; n <= last; n++ {
+			date := anchor.AddDate(0, n*stepMonths, 0)
+			if !date.Before(start) && !date.After(end) {
+				dates = append(dates, date)
+			}
+		}
+	default:
+		count := monthsBetween(start, end)
+		if end.Day() >= start.Day() {
+			count++
+		}
+		for n := 0; n < max(count, 1); n++ {
+			month := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+			day := min(start.Day(), month.AddDate(0, 1, -1).Day())
+			dates = append(dates, month.AddDate(0, 0, day-1))
+		}
+	}
+	return dates
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+// charges returns the sum and the number of the charges of sub between start
+// and end at the price in effect on each charge date.
+func charges(sub *model.Subscription, start, end time.Time) (model.Money, int64, error) {
+	var total model.Money
+	dates := chargeDates(sub, start, end)
+	for _, date := range dates {
+		var err error
+		if total, err = total.Add(sub.PriceAt(date)); err != nil {
+			return 0, 0, err
+		}
+	}
+	return total, int64(len(dates)), nil
+}
+
+func chargeCurrency(sub *model.Subscription) string {
+	if sub.Currency == "" {
+		return model.DefaultCurrency
+	}
+	return sub.Currency
+}
+
+// calcTotal adds up the charges of subs between from and to by currency.
+// PriceChanges of every subscription must be sorted and Price must be the
+// stored price.
+func calcTotal(subs []model.Subscription, from, to *time.Time, now time.Time) (map[string]model.Money, error) {
+	totals := make(map[string]model.Money)
+	for i := range subs {
+		start, end := chargeWindow(&subs[i], from, to, now)
+		total, count, err := charges(&subs[i], start, end)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+		currency := chargeCurrency(&subs[i])
+		if totals[currency], err = totals[currency].Add(total); err != nil {
+			return nil, err
+		}
+	}
+	return totals, nil
+}
+
+// calcCharges returns the charges of every subscription in subs between from
+// and to, ordered by subscription ID.
+func calcCharges(subs []model.Subscription, from, to *time.Time, now time.Time) ([]model.SubscriptionCharges, error) {
+	result := make([]model.SubscriptionCharges, 0, len(subs))
+	for i := range subs {
+		start, end := chargeWindow(&subs[i], from, to, now)
+		total, count, err := charges(&subs[i], start, end)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+		result = append(result, model.SubscriptionCharges{
+			SubscriptionID: subs[i].ID,
+			UserID:         subs[i].UserID,
+			SplitMethod:    subs[i].SplitMethod,
+			Currency:       chargeCurrency(&subs[i]),
+			Total:          total,
+			Charges:        count,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].SubscriptionID[:], result[j].SubscriptionID[:]) < 0
+	})
+	return result, nil
+}
+
+// calcMonthly splits the charges of subs between from and to by calendar
+// month and service, ordered by month, service and currency.
+func calcMonthly(subs []model.Subscription, from, to time.Time, now time.Time) ([]model.ServiceMonthTotal, error) {
+	type key struct{ month, service, currency string }
+	sums := make(map[key]model.Money)
+
+	from, to = from.UTC(), to.UTC()
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(to); month = month.AddDate(0, 1, 0) {
+		monthFrom := month
+		if from.After(monthFrom) {
+			monthFrom = from
+		}
+		monthTo := month.AddDate(0, 1, 0).Add(-time.Microsecond)
+		if to.Before(monthTo) {
+			monthTo = to
+		}
+		for i := range subs {
+			start, end := chargeWindow(&subs[i], &monthFrom, &monthTo, now)
+			total, count, err := charges(&subs[i], start, end)
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				continue
+			}
+			k := key{month.Format("2006-01"), subs[i].ServiceName, chargeCurrency(&subs[i])}
+			if sums[k], err = sums[k].Add(total); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	totals := make([]model.ServiceMonthTotal, 0, len(sums))
+	for k, total := range sums {
+		totals = append(totals, model.ServiceMonthTotal{Month: k.month, ServiceName: k.service, Currency: k.currency, Total: total})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		a, b := totals[i], totals[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		return a.Currency < b.Currency
+	})
+	return totals, nil
+}
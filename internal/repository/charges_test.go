@@ -0,0 +1,244 @@
+package repository_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository/repotest"
+)
+
+// diffMonths, countCharges and referenceTotal are the Go calculation CalcTotal
+// used before it moved into SQL. They stay here as the reference every
+// repository must agree with for subscriptions without price changes.
+
+func diffMonths(from, to time.Time) int {
+	yearDiff := to.Year() - from.Year()
+	monthDiff := int(to.Month()) - int(from.Month())
+
+	months := yearDiff*12 + monthDiff
+
+	if to.Day() >= from.Day() {
+		months++
+	}
+	return months
+}
+
+func countCharges(sub model.Subscription, from, to time.Time) int {
+	if sub.BillingPeriod == model.BillingMonthly || !sub.BillingPeriod.Valid(sub.BillingPeriodDays) {
+		months := diffMonths(from, to)
+		if months == 0 {
+			months = 1
+		}
+		return months
+	}
+
+	charges := 0
+	for n := 0; ; n++ {
+		date := sub.BillingPeriod.ChargeDate(sub.StartDate, sub.BillingPeriodDays, n)
+		if date.After(to) {
+			break
+		}
+		if !date.Before(from) {
+			charges++
+		}
+	}
+	return charges
+}
+
+func referenceTotal(subs []model.Subscription, from, to *time.Time, now time.Time) (map[string]model.Money, error) {
+	totals := make(map[string]model.Money)
+	for _, sub := range subs {
+		start := sub.StartDate
+		end := now
+		if sub.EndDate != nil {
+			end = *sub.EndDate
+		}
+
+		if from != nil && start.Before(*from) {
+			start = *from
+		}
+		if to != nil && end.After(*to) {
+			end = *to
+		}
+
+		if end.Before(start) {
+			continue
+		}
+
+		currency := sub.Currency
+		if currency == "" {
+			currency = model.DefaultCurrency
+		}
+
+		cost, err := sub.Price.Mul(int64(countCharges(sub, start, end)))
+		if err == nil {
+			totals[currency], err = totals[currency].Add(cost)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The reference reports currencies without charges as zero, the
+	// repositories leave them out.
+	maps.DeleteFunc(totals, func(_ string, total model.Money) bool { return total == 0 })
+	return totals, nil
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func subscription(price model.Money, period model.BillingPeriod, start time.Time, end *time.Time) model.Subscription {
+	sub := model.Subscription{
+		UserID:        repotest.Users[0],
+		ServiceName:   "service",
+		Price:         price,
+		Currency:      model.DefaultCurrency,
+		BillingPeriod: period,
+		StartDate:     start,
+		EndDate:       end,
+	}
+	if period == model.BillingCustom {
+		sub.BillingPeriodDays = 10
+	}
+	return sub
+}
+
+var referenceCases = []struct {
+	name     string
+	subs     []model.Subscription
+	from, to *time.Time
+}{
+	{
+		name: "month-end start through the end of March",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 31), ptr(date(2024, 3, 31)))},
+	},
+	{
+		name: "month-end start over a leap February",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 31), nil)},
+		from: ptr(date(2024, 2, 1)), to: ptr(date(2024, 2, 29)),
+	},
+	{
+		name: "start on the 30th ending on February 28th",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2023, 1, 30), ptr(date(2023, 2, 28)))},
+	},
+	{
+		name: "open-ended within a range",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 15), nil)},
+		from: ptr(date(2024, 2, 1)), to: ptr(date(2024, 6, 30)),
+	},
+	{
+		name: "open-ended without a range",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2023, 5, 10), nil)},
+	},
+	{
+		name: "open-ended from a date",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2023, 5, 10), nil)},
+		from: ptr(date(2024, 1, 20)),
+	},
+	{
+		name: "range before the subscription",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 3, 1), ptr(date(2024, 6, 30)))},
+		from: ptr(date(2024, 1, 1)), to: ptr(date(2024, 2, 29)),
+	},
+	{
+		name: "range after the subscription",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 1), ptr(date(2024, 2, 29)))},
+		from: ptr(date(2024, 4, 1)), to: ptr(date(2024, 5, 31)),
+	},
+	{
+		name: "range starting on the end date",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 1), ptr(date(2024, 2, 29)))},
+		from: ptr(date(2024, 2, 29)), to: ptr(date(2024, 5, 31)),
+	},
+	{
+		name: "single-month range",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 10), nil)},
+		from: ptr(date(2024, 3, 1)), to: ptr(date(2024, 3, 31)),
+	},
+	{
+		name: "single-day range",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 10), nil)},
+		from: ptr(date(2024, 3, 10)), to: ptr(date(2024, 3, 10)),
+	},
+	{
+		name: "part of a month",
+		subs: []model.Subscription{subscription(1000, model.BillingMonthly, date(2024, 1, 10), nil)},
+		from: ptr(date(2024, 3, 5)), to: ptr(date(2024, 3, 20)),
+	},
+	{
+		name: "yearly from a leap day",
+		subs: []model.Subscription{subscription(12000, model.BillingYearly, date(2024, 2, 29), ptr(date(2028, 3, 1)))},
+	},
+	{
+		name: "yearly with the anniversary in range",
+		subs: []model.Subscription{subscription(12000, model.BillingYearly, date(2023, 6, 15), nil)},
+		from: ptr(date(2024, 1, 1)), to: ptr(date(2024, 12, 31)),
+	},
+	{
+		name: "yearly without the anniversary in range",
+		subs: []model.Subscription{subscription(12000, model.BillingYearly, date(2023, 6, 15), nil)},
+		from: ptr(date(2024, 7, 1)), to: ptr(date(2024, 12, 31)),
+	},
+	{
+		name: "quarterly from a month end",
+		subs: []model.Subscription{subscription(3000, model.BillingQuarterly, date(2024, 1, 31), ptr(date(2024, 12, 31)))},
+	},
+	{
+		name: "weekly over a quarter",
+		subs: []model.Subscription{subscription(250, model.BillingWeekly, date(2024, 1, 1), ptr(date(2024, 3, 31)))},
+	},
+	{
+		name: "custom period within a month",
+		subs: []model.Subscription{subscription(400, model.BillingCustom, date(2024, 1, 5), nil)},
+		from: ptr(date(2024, 2, 1)), to: ptr(date(2024, 2, 29)),
+	},
+	{
+		name: "several subscriptions and currencies",
+		subs: []model.Subscription{
+			subscription(1000, model.BillingMonthly, date(2024, 1, 31), nil),
+			subscription(12000, model.BillingYearly, date(2023, 3, 1), nil),
+			func() model.Subscription {
+				sub := subscription(999, model.BillingWeekly, date(2024, 2, 1), ptr(date(2024, 5, 1)))
+				sub.Currency = "USD"
+				return sub
+			}(),
+		},
+		from: ptr(date(2024, 1, 1)), to: ptr(date(2024, 12, 31)),
+	},
+}
+
+// testCalcTotalMatchesReference checks CalcTotal of the repositories returned
+// by newRepo against referenceTotal.
+func testCalcTotalMatchesReference(t *testing.T, newRepo repotest.NewRepo) {
+	for _, test := range referenceCases {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+			for _, sub := range test.subs {
+				if err := repo.Create(ctx, &sub); err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+			}
+
+			want, err := referenceTotal(test.subs, test.from, test.to, time.Now())
+			if err != nil {
+				t.Fatalf("referenceTotal: %v", err)
+			}
+			got, err := repo.CalcTotal(ctx, model.SubscriptionFilter{UserID: repotest.Users[0]}, test.from, test.to)
+			if err != nil {
+				t.Fatalf("CalcTotal: %v", err)
+			}
+			if !maps.Equal(got, want) {
+				t.Errorf("CalcTotal = %v, reference = %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"subscription-aggregator/internal/apperror"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the repositories in place of driver errors, so that
+// callers can tell a missing record from a database failure.
+var (
+	ErrNotFound    = apperror.NotFound("record not found")
+	ErrConflict    = apperror.Conflict("record conflicts with existing data")
+	ErrUnavailable = apperror.New(apperror.KindUnavailable, "database is unavailable")
+	ErrTimeout     = apperror.New(apperror.KindTimeout, "the request timed out")
+	ErrCanceled    = apperror.New(apperror.KindCanceled, "the request was canceled")
+)
+
+// translateError maps GORM and pgx errors to ErrNotFound, ErrConflict,
+// ErrUnavailable, ErrTimeout or ErrCanceled. Other errors, and errors that
+// are already translated, are returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := apperror.As(err); ok {
+		return err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	// A query stopped by its context fails with the driver's error for the
+	// interrupted connection, which still wraps the reason.
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "23505", pgErr.Code == "23503":
+			// unique_violation, foreign_key_violation
+			return fmt.Errorf("%w (%s)", ErrConflict, pgErr.ConstraintName)
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), pgErr.Code == "57P01", pgErr.Code == "57P03":
+			// connection exception, insufficient resources, admin shutdown,
+			// cannot connect now
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		case pgErr.Code == "57014":
+			// query_canceled, raised by statement_timeout
+			return fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+		return err
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
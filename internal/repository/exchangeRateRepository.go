@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExchangeRateRepository interface {
+	Upsert(ctx context.Context, rates []model.ExchangeRate) error
+	GetAll(ctx context.Context) ([]model.ExchangeRate, error)
+	Get(ctx context.Context, currency string) (*model.ExchangeRate, error)
+}
+
+type exchangeRateRepo struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) ExchangeRateRepository {
+	logger.Log.Info("Creating new ExchangeRateRepository")
+	return &exchangeRateRepo{db: db}
+}
+
+func (r *exchangeRateRepo) Upsert(ctx context.Context, rates []model.ExchangeRate) error {
+	logger.Log.Infof("Upserting %d exchange rates", len(rates))
+	if len(rates) == 0 {
+		return nil
+	}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&rates).Error
+	if err != nil {
+		logger.Log.Errorf("Error upserting exchange rates: %v", err)
+	} else {
+		logger.Log.Infof("Exchange rates upserted successfully")
+	}
+	return translateError(err)
+}
+
+func (r *exchangeRateRepo) GetAll(ctx context.Context) ([]model.ExchangeRate, error) {
+	logger.Log.Info("Getting all exchange rates")
+	var rates []model.ExchangeRate
+	err := r.db.WithContext(ctx).Order("currency").Find(&rates).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving exchange rates: %v", err)
+		return nil, translateError(err)
+	}
+	logger.Log.Infof("Retrieved %d exchange rates", len(rates))
+	return rates, nil
+}
+
+func (r *exchangeRateRepo) Get(ctx context.Context, currency string) (*model.ExchangeRate, error) {
+	logger.Log.Infof("Getting exchange rate for %s", currency)
+	var rate model.ExchangeRate
+	err := r.db.WithContext(ctx).First(&rate, "currency = ?", currency).Error
+	if err != nil {
+		logger.Log.Errorf("Exchange rate for %s not found: %v", currency, err)
+		return nil, translateError(err)
+	}
+	return &rate, nil
+}
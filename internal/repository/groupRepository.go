@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GroupRepository interface {
+	Create(ctx context.Context, group *model.Group) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Group, error)
+	GetByUser(ctx context.Context, userID string) ([]model.Group, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetMember(ctx context.Context, groupID uuid.UUID, userID string) (*model.GroupMember, error)
+	SetMember(ctx context.Context, member *model.GroupMember) error
+	RemoveMember(ctx context.Context, groupID uuid.UUID, userID string) error
+	CountOwners(ctx context.Context, groupID uuid.UUID) (int64, error)
+}
+
+type groupRepo struct {
+	db *gorm.DB
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	logger.Log.Info("Creating new GroupRepository")
+	return &groupRepo{db: db}
+}
+
+// Create stores the group together with its members.
+func (r *groupRepo) Create(ctx context.Context, group *model.Group) error {
+	logger.Log.Infof("Creating group %s", group.Name)
+	err := r.db.WithContext(ctx).Create(group).Error
+	if err != nil {
+		logger.Log.Errorf("Error creating group: %v", err)
+	} else {
+		logger.Log.Infof("Group created successfully: %s", group.ID)
+	}
+	return translateError(err)
+}
+
+func (r *groupRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Group, error) {
+	logger.Log.Infof("Getting group by ID: %s", id)
+	var group model.Group
+	err := r.db.WithContext(ctx).Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("user_id")
+	}).First(&group, "id = ?", id).Error
+	if err != nil {
+		logger.Log.Errorf("Group with ID %s not found: %v", id, err)
+		return nil, translateError(err)
+	}
+	return &group, nil
+}
+
+func (r *groupRepo) GetByUser(ctx context.Context, userID string) ([]model.Group, error) {
+	logger.Log.Infof("Getting groups of user %s", userID)
+	var groups []model.Group
+	err := r.db.WithContext(ctx).
+		Where("id IN (?)", r.db.Model(&model.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Order("name, id").
+		Find(&groups).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving groups: %v", err)
+		return nil, translateError(err)
+	}
+	logger.Log.Infof("Retrieved %d groups", len(groups))
+	return groups, nil
+}
+
+// Delete removes the group with its memberships. Its subscriptions stay with
+// their users.
+func (r *groupRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	logger.Log.Infof("Deleting group with ID: %s", id)
+	result := r.db.WithContext(ctx).Delete(&model.Group{}, "id = ?", id)
+	if result.Error != nil {
+		logger.Log.Errorf("Error deleting group %s: %v", id, result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	logger.Log.Infof("Group %s deleted successfully", id)
+	return nil
+}
+
+func (r *groupRepo) GetMember(ctx context.Context, groupID uuid.UUID, userID string) (*model.GroupMember, error) {
+	var member model.GroupMember
+	err := r.db.WithContext(ctx).First(&member, "group_id = ? AND user_id = ?", groupID, userID).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &member, nil
+}
+
+// SetMember adds the member or changes their role.
+func (r *groupRepo) SetMember(ctx context.Context, member *model.GroupMember) error {
+	logger.Log.Infof("Setting role of %s in group %s to %s", member.UserID, member.GroupID, member.Role)
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(member).Error
+	if err != nil {
+		logger.Log.Errorf("Error setting group member: %v", err)
+	}
+	return translateError(err)
+}
+
+func (r *groupRepo) RemoveMember(ctx context.Context, groupID uuid.UUID, userID string) error {
+	logger.Log.Infof("Removing %s from group %s", userID, groupID)
+	result := r.db.WithContext(ctx).Delete(&model.GroupMember{}, "group_id = ? AND user_id = ?", groupID, userID)
+	if result.Error != nil {
+		logger.Log.Errorf("Error removing group member: %v", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *groupRepo) CountOwners(ctx context.Context, groupID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.GroupMember{}).
+		Where("group_id = ? AND role = ?", groupID, model.RoleOwner).
+		Count(&count).Error
+	if err != nil {
+		logger.Log.Errorf("Error counting owners of group %s: %v", groupID, err)
+	}
+	return count, translateError(err)
+}
@@ -0,0 +1,33 @@
+package repository_test
+
+import (
+	"os"
+	"testing"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository/repotest"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	// The repositories log through logger.Log, which is nil until initialised.
+	logger.InitLogger()
+	logger.Log.SetLevel(logrus.WarnLevel)
+	os.Exit(m.Run())
+}
+
+// seed creates the users and the group the conformance suite refers to.
+func seed(t testing.TB, db *gorm.DB) {
+	t.Helper()
+	for _, id := range repotest.Users {
+		if err := db.Create(&model.User{ID: id}).Error; err != nil {
+			t.Fatalf("create user %s: %v", id, err)
+		}
+	}
+	if err := db.Create(&model.Group{ID: repotest.GroupID, Name: "family"}).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+	"time"
+)
+
+type memoryAuditRepo struct {
+	store *MemoryStore
+	inTx  bool
+}
+
+func NewMemoryAuditRepository(store *MemoryStore) AuditRepository {
+	logger.Log.Info("Creating new memory AuditRepository")
+	return &memoryAuditRepo{store: store}
+}
+
+func (r *memoryAuditRepo) Create(ctx context.Context, entry *model.AuditEntry) error {
+	logger.Log.Infof("Recording %s of subscription %s by %q", entry.Action, entry.SubscriptionID, entry.Actor)
+	defer r.store.lock(r.inTx)()
+
+	entry.ID = r.store.nextAuditID
+	r.store.nextAuditID++
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	r.store.audit = append(r.store.audit, *entry)
+	return nil
+}
+
+// GetList returns the entries matching filter, newest first.
+func (r *memoryAuditRepo) GetList(ctx context.Context, filter model.AuditFilter, offset, limit int) ([]model.AuditEntry, int64, error) {
+	logger.Log.Infof("Getting audit entries for %+v with offset %d, limit %d", filter, offset, limit)
+	defer r.store.lock(r.inTx)()
+
+	var entries []model.AuditEntry
+	for _, entry := range r.store.audit {
+		if filter.SubscriptionID != nil && entry.SubscriptionID != *filter.SubscriptionID {
+			continue
+		}
+		if filter.UserID != "" && entry.UserID != filter.UserID && entry.Actor != filter.UserID {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+
+	total := int64(len(entries))
+	entries = page(entries, offset, limit)
+	logger.Log.Infof("Retrieved %d of %d audit entries", len(entries), total)
+	return entries, total, nil
+}
+
+// page returns the part of items that a query with offset and limit returns;
+// a negative offset and a non-positive limit don't restrict anything.
+func page[T any](items []T, offset, limit int) []T {
+	if offset > 0 {
+		items = items[min(offset, len(items)):]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps subscriptions and the audit log in the process memory for
+// local runs and tests. Nothing survives a restart.
+//
+// Stored subscriptions are never modified in place: every write stores a new
+// copy, so a transaction can be rolled back by restoring the map it started
+// with.
+type MemoryStore struct {
+	mu          sync.Mutex
+	subs        map[uuid.UUID]*model.Subscription
+	audit       []model.AuditEntry
+	nextAuditID uint64
+	users       UserRepository
+	groups      GroupRepository
+}
+
+// NewMemoryStore returns an empty store. The users and groups subscriptions
+// refer to are looked up in users and groups, which stand in for the foreign
+// keys of the database schemas.
+func NewMemoryStore(users UserRepository, groups GroupRepository) *MemoryStore {
+	logger.Log.Info("Creating new MemoryStore")
+	return &MemoryStore{
+		subs:        make(map[uuid.UUID]*model.Subscription),
+		nextAuditID: 1,
+		users:       users,
+		groups:      groups,
+	}
+}
+
+// checkUser fails with ErrConflict, like a foreign key violation, unless the
+// user exists.
+func (s *MemoryStore) checkUser(ctx context.Context, userID string) error {
+	_, err := s.users.GetByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		logger.Log.Errorf("User %s does not exist", userID)
+		return ErrConflict
+	}
+	return err
+}
+
+// checkGroup fails with ErrConflict, like a foreign key violation, unless the
+// group exists.
+func (s *MemoryStore) checkGroup(ctx context.Context, groupID uuid.UUID) error {
+	_, err := s.groups.GetByID(ctx, groupID)
+	if errors.Is(err, ErrNotFound) {
+		logger.Log.Errorf("Group %s does not exist", groupID)
+		return ErrConflict
+	}
+	return err
+}
+
+// lock locks the store unless the caller runs in a transaction that already
+// holds the lock, and returns the function that unlocks it.
+func (s *MemoryStore) lock(inTx bool) func() {
+	if inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+type memoryTransactor struct {
+	store *MemoryStore
+}
+
+// NewMemoryTransactor returns a Transactor for the repositories of store.
+// Transactions run one at a time and block all other access to the store.
+func NewMemoryTransactor(store *MemoryStore) Transactor {
+	logger.Log.Info("Creating new memory Transactor")
+	return &memoryTransactor{store: store}
+}
+
+// Transaction rolls back the changes of fn when it returns an error or
+// panics. A panic is passed on after the rollback.
+func (t *memoryTransactor) Transaction(ctx context.Context, fn func(subs SubscriptionRepository, audit AuditRepository) error) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	subs, audit, nextAuditID := maps.Clone(t.store.subs), len(t.store.audit), t.store.nextAuditID
+	rollback := func() {
+		t.store.subs, t.store.audit, t.store.nextAuditID = subs, t.store.audit[:audit], nextAuditID
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	err := fn(&memorySubscriptionRepo{store: t.store, inTx: true}, &memoryAuditRepo{store: t.store, inTx: true})
+	if err != nil {
+		rollback()
+	}
+	return err
+}
+
+// cloneSubscription returns a deep copy of sub.
+func cloneSubscription(sub *model.Subscription) *model.Subscription {
+	clone := *sub
+	if sub.GroupID != nil {
+		groupID := *sub.GroupID
+		clone.GroupID = &groupID
+	}
+	if sub.EndDate != nil {
+		endDate := *sub.EndDate
+		clone.EndDate = &endDate
+	}
+	clone.PriceChanges = append([]model.PriceChange(nil), sub.PriceChanges...)
+	clone.Shares = make([]model.SplitShare, len(sub.Shares))
+	for i, share := range sub.Shares {
+		clone.Shares[i] = cloneShare(share)
+	}
+	if len(clone.Shares) == 0 {
+		clone.Shares = nil
+	}
+	clone.User, clone.Group = nil, nil
+	return &clone
+}
+
+func cloneShare(share model.SplitShare) model.SplitShare {
+	if share.Percent != nil {
+		percent := *share.Percent
+		share.Percent = &percent
+	}
+	if share.Amount != nil {
+		amount := *share.Amount
+		share.Amount = &amount
+	}
+	share.User = nil
+	return share
+}
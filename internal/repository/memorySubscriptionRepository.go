@@ -0,0 +1,548 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"sort"
+	"strings"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memorySubscriptionRepo keeps subscriptions in a MemoryStore. It behaves like
+// subscriptionRepo, except that user and group IDs are not checked.
+type memorySubscriptionRepo struct {
+	store *MemoryStore
+	inTx  bool
+}
+
+func NewMemorySubscriptionRepository(store *MemoryStore) SubscriptionRepository {
+	logger.Log.Info("Creating new memory SubscriptionRepository")
+	return &memorySubscriptionRepo{store: store}
+}
+
+func (r *memorySubscriptionRepo) Create(ctx context.Context, sub *model.Subscription) error {
+	logger.Log.Infof("Creating subscription for user %s, service %s", sub.UserID, sub.ServiceName)
+	defer r.store.lock(r.inTx)()
+
+	if sub.ID == uuid.Nil {
+		sub.ID = uuid.New()
+	}
+	if _, ok := r.store.subs[sub.ID]; ok {
+		logger.Log.Errorf("Error creating subscription: ID %s is taken", sub.ID)
+		return ErrConflict
+	}
+	if err := r.store.checkUser(ctx, sub.UserID); err != nil {
+		return err
+	}
+	if sub.GroupID != nil {
+		if err := r.store.checkGroup(ctx, *sub.GroupID); err != nil {
+			return err
+		}
+	}
+	if sub.Currency == "" {
+		sub.Currency = model.DefaultCurrency
+	}
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = model.BillingMonthly
+	}
+	sub.PriceChanges = []model.PriceChange{{
+		ID:             uuid.New(),
+		SubscriptionID: sub.ID,
+		Price:          sub.Price,
+		EffectiveFrom:  sub.StartDate,
+		CreatedAt:      time.Now(),
+	}}
+	for i := range sub.Shares {
+		sub.Shares[i].SubscriptionID = sub.ID
+	}
+	sub.Version = 1
+	r.store.subs[sub.ID] = cloneSubscription(sub)
+
+	logger.Log.Infof("Subscription created with ID %s", sub.ID)
+	return nil
+}
+
+func (r *memorySubscriptionRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	logger.Log.Infof("Getting subscription by ID %s", id)
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[id]
+	if !ok || stored.DeletedAt.Valid {
+		logger.Log.Errorf("Subscription with ID %s not found", id)
+		return nil, ErrNotFound
+	}
+	return withCurrentPrice(stored, time.Now()), nil
+}
+
+// Update saves sub if it still has sub.Version in the store and bumps the
+// version on success.
+func (r *memorySubscriptionRepo) Update(ctx context.Context, sub *model.Subscription) error {
+	logger.Log.Infof("Updating subscription with ID %s at version %d", sub.ID, sub.Version)
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[sub.ID]
+	if !ok || stored.DeletedAt.Valid || stored.Version != sub.Version {
+		logger.Log.Warnf("Subscription ID %s is no longer at version %d", sub.ID, sub.Version)
+		return ErrVersionConflict
+	}
+	// Like foreign keys, references are only checked when they change.
+	if sub.UserID != stored.UserID {
+		if err := r.store.checkUser(ctx, sub.UserID); err != nil {
+			return err
+		}
+	}
+	if sub.GroupID != nil && (stored.GroupID == nil || *stored.GroupID != *sub.GroupID) {
+		if err := r.store.checkGroup(ctx, *sub.GroupID); err != nil {
+			return err
+		}
+	}
+
+	// Prices are only changed through AddPriceChange so that past totals stay intact.
+	updated := cloneSubscription(sub)
+	updated.Price = stored.Price
+	updated.PriceChanges = stored.PriceChanges
+	updated.Shares = stored.Shares
+	updated.Version++
+	r.store.subs[sub.ID] = updated
+	sub.Version++
+
+	logger.Log.Infof("Subscription ID %s updated successfully to version %d", sub.ID, sub.Version)
+	return nil
+}
+
+// BumpVersion moves the subscription from version to the next one for a
+// change made outside of Update, such as a new price.
+func (r *memorySubscriptionRepo) BumpVersion(ctx context.Context, id uuid.UUID, version uint) error {
+	logger.Log.Infof("Bumping version of subscription %s from %d", id, version)
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[id]
+	if !ok || stored.DeletedAt.Valid || stored.Version != version {
+		logger.Log.Warnf("Subscription ID %s is no longer at version %d", id, version)
+		return ErrVersionConflict
+	}
+	updated := cloneSubscription(stored)
+	updated.Version++
+	r.store.subs[id] = updated
+
+	logger.Log.Infof("Subscription ID %s is now at version %d", id, updated.Version)
+	return nil
+}
+
+// Delete marks the subscription deleted. It stays in the store, left out of
+// lists and totals, until it is restored or purged.
+func (r *memorySubscriptionRepo) Delete(ctx context.Context, id uuid.UUID, version uint) error {
+	logger.Log.Infof("Deleting subscription with ID %s at version %d", id, version)
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[id]
+	if !ok || stored.DeletedAt.Valid || stored.Version != version {
+		logger.Log.Warnf("Subscription ID %s is no longer at version %d", id, version)
+		return ErrVersionConflict
+	}
+	deleted := cloneSubscription(stored)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.store.subs[id] = deleted
+
+	logger.Log.Infof("Subscription ID %s deleted successfully", id)
+	return nil
+}
+
+// GetDeletedByID returns a subscription marked deleted.
+func (r *memorySubscriptionRepo) GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	logger.Log.Infof("Getting deleted subscription by ID %s", id)
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[id]
+	if !ok || !stored.DeletedAt.Valid {
+		logger.Log.Errorf("Deleted subscription with ID %s not found", id)
+		return nil, ErrNotFound
+	}
+	return withCurrentPrice(stored, time.Now()), nil
+}
+
+// Restore brings back a deleted subscription. It returns ErrNotFound unless
+// the subscription is marked deleted.
+func (r *memorySubscriptionRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	logger.Log.Infof("Restoring subscription with ID %s", id)
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[id]
+	if !ok || !stored.DeletedAt.Valid {
+		logger.Log.Warnf("Subscription ID %s is not deleted", id)
+		return ErrNotFound
+	}
+	restored := cloneSubscription(stored)
+	restored.DeletedAt = gorm.DeletedAt{}
+	restored.Version++
+	r.store.subs[id] = restored
+
+	logger.Log.Infof("Subscription ID %s restored successfully", id)
+	return nil
+}
+
+// PurgeDeleted permanently removes the subscriptions deleted before the given
+// time, together with their price history and shares.
+func (r *memorySubscriptionRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	logger.Log.Infof("Purging subscriptions deleted before %v", before)
+	defer r.store.lock(r.inTx)()
+
+	var purged int64
+	for id, stored := range r.store.subs {
+		if stored.DeletedAt.Valid && stored.DeletedAt.Time.Before(before) {
+			delete(r.store.subs, id)
+			purged++
+		}
+	}
+	logger.Log.Infof("Purged %d subscriptions", purged)
+	return purged, nil
+}
+
+// GetList returns one page of the subscriptions matching filter along with
+// the number of matching subscriptions.
+func (r *memorySubscriptionRepo) GetList(ctx context.Context, filter model.SubscriptionFilter, offset, limit int) ([]model.Subscription, int64, error) {
+	logger.Log.Infof("Getting subscriptions list with filter %+v, offset %d and limit %d", filter, offset, limit)
+	defer r.store.lock(r.inTx)()
+
+	now := time.Now()
+	matched := r.find(filter, now)
+	sortSubscriptions(matched, filter, now)
+
+	total := int64(len(matched))
+	subs := make([]model.Subscription, 0, len(matched))
+	for _, stored := range page(matched, offset, limit) {
+		subs = append(subs, *withCurrentPrice(stored, now))
+	}
+
+	logger.Log.Infof("Retrieved %d of %d subscriptions", len(subs), total)
+	return subs, total, nil
+}
+
+// GetListAfter returns up to limit subscriptions ordered by (start_date, id)
+// that come after the cursor, or before it for a backward cursor, and
+// whether there are more of them past the returned ones.
+func (r *memorySubscriptionRepo) GetListAfter(ctx context.Context, filter model.SubscriptionFilter, cursor *model.Cursor, limit int) ([]model.Subscription, bool, error) {
+	logger.Log.Infof("Getting subscriptions list with filter %+v after cursor %+v, limit %d", filter, cursor, limit)
+	defer r.store.lock(r.inTx)()
+
+	desc := filter.SortDesc
+	if cursor != nil && cursor.Backward {
+		desc = !desc
+	}
+
+	now := time.Now()
+	var matched []*model.Subscription
+	for _, stored := range r.find(filter, now) {
+		if cursor != nil {
+			order := compareStart(stored, cursor.StartDate, cursor.ID)
+			if (desc && order >= 0) || (!desc && order <= 0) {
+				continue
+			}
+		}
+		matched = append(matched, stored)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		order := compareStart(matched[i], matched[j].StartDate, matched[j].ID)
+		if desc {
+			return order > 0
+		}
+		return order < 0
+	})
+
+	hasMore := len(matched) > limit
+	if hasMore {
+		matched = matched[:limit]
+	}
+	subs := make([]model.Subscription, 0, len(matched))
+	for _, stored := range matched {
+		subs = append(subs, *withCurrentPrice(stored, now))
+	}
+	if cursor != nil && cursor.Backward {
+		for i, j := 0, len(subs)-1; i < j; i, j = i+1, j-1 {
+			subs[i], subs[j] = subs[j], subs[i]
+		}
+	}
+
+	logger.Log.Infof("Retrieved %d subscriptions, more: %t", len(subs), hasMore)
+	return subs, hasMore, nil
+}
+
+func (r *memorySubscriptionRepo) CalcTotal(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) (map[string]model.Money, error) {
+	logger.Log.Infof("Calculating total subscription cost for %+v, from %v to %v", filter, from, to)
+	defer r.store.lock(r.inTx)()
+
+	totals, err := calcTotal(r.charged(filter, from, to), from, to, time.Now())
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription totals: %v", err)
+		return nil, err
+	}
+	logger.Log.Infof("Total subscription cost calculated: %v", totals)
+	return totals, nil
+}
+
+func (r *memorySubscriptionRepo) CalcMonthly(ctx context.Context, filter model.SubscriptionFilter, from, to time.Time) ([]model.ServiceMonthTotal, error) {
+	logger.Log.Infof("Calculating monthly subscription cost for %+v, from %v to %v", filter, from, to)
+	defer r.store.lock(r.inTx)()
+
+	totals, err := calcMonthly(r.charged(filter, &from, &to), from, to, time.Now())
+	if err != nil {
+		logger.Log.Errorf("Error calculating monthly subscription costs: %v", err)
+		return nil, err
+	}
+	logger.Log.Infof("Calculated %d monthly service totals", len(totals))
+	return totals, nil
+}
+
+func (r *memorySubscriptionRepo) CalcCharges(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) ([]model.SubscriptionCharges, error) {
+	logger.Log.Infof("Calculating subscription charges for %+v, from %v to %v", filter, from, to)
+	defer r.store.lock(r.inTx)()
+
+	charges, err := calcCharges(r.charged(filter, from, to), from, to, time.Now())
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription charges: %v", err)
+		return nil, err
+	}
+	logger.Log.Infof("Calculated charges of %d subscriptions", len(charges))
+	return charges, nil
+}
+
+func (r *memorySubscriptionRepo) AddPriceChange(ctx context.Context, change *model.PriceChange) error {
+	logger.Log.Infof("Setting price %s for subscription %s from %s", change.Price, change.SubscriptionID, change.EffectiveFrom.Format("2006-01-02"))
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[change.SubscriptionID]
+	if !ok {
+		logger.Log.Errorf("Error setting price for subscription %s: no such subscription", change.SubscriptionID)
+		return ErrConflict
+	}
+
+	updated := cloneSubscription(stored)
+	replaced := false
+	for i := range updated.PriceChanges {
+		if updated.PriceChanges[i].EffectiveFrom.Equal(change.EffectiveFrom) {
+			updated.PriceChanges[i].Price = change.Price
+			change.ID = updated.PriceChanges[i].ID
+			replaced = true
+		}
+	}
+	if !replaced {
+		if change.ID == uuid.Nil {
+			change.ID = uuid.New()
+		}
+		if change.CreatedAt.IsZero() {
+			change.CreatedAt = time.Now()
+		}
+		updated.PriceChanges = append(updated.PriceChanges, *change)
+		sort.Slice(updated.PriceChanges, func(i, j int) bool {
+			return updated.PriceChanges[i].EffectiveFrom.Before(updated.PriceChanges[j].EffectiveFrom)
+		})
+	}
+	r.store.subs[change.SubscriptionID] = updated
+
+	logger.Log.Infof("Price for subscription %s set successfully", change.SubscriptionID)
+	return nil
+}
+
+func (r *memorySubscriptionRepo) GetPriceChanges(ctx context.Context, subscriptionID uuid.UUID) ([]model.PriceChange, error) {
+	logger.Log.Infof("Getting price history of subscription %s", subscriptionID)
+	defer r.store.lock(r.inTx)()
+
+	var changes []model.PriceChange
+	if stored, ok := r.store.subs[subscriptionID]; ok {
+		changes = append(changes, stored.PriceChanges...)
+	}
+	logger.Log.Infof("Retrieved %d price changes", len(changes))
+	return changes, nil
+}
+
+// SetSplit replaces the split of a subscription. An empty method with no
+// shares removes it.
+func (r *memorySubscriptionRepo) SetSplit(ctx context.Context, subscriptionID uuid.UUID, method model.SplitMethod, shares []model.SplitShare) error {
+	logger.Log.Infof("Setting %s split of subscription %s between %d users", method, subscriptionID, len(shares))
+	defer r.store.lock(r.inTx)()
+
+	stored, ok := r.store.subs[subscriptionID]
+	if !ok {
+		if len(shares) == 0 {
+			return nil
+		}
+		logger.Log.Errorf("Error setting split of subscription %s: no such subscription", subscriptionID)
+		return ErrConflict
+	}
+
+	updated := cloneSubscription(stored)
+	if !updated.DeletedAt.Valid {
+		updated.SplitMethod = method
+	}
+	updated.Shares = nil
+	seen := make(map[string]bool, len(shares))
+	for i := range shares {
+		if seen[shares[i].UserID] {
+			logger.Log.Errorf("Error setting split of subscription %s: user %s is listed twice", subscriptionID, shares[i].UserID)
+			return ErrConflict
+		}
+		seen[shares[i].UserID] = true
+		if err := r.store.checkUser(ctx, shares[i].UserID); err != nil {
+			return err
+		}
+		shares[i].SubscriptionID = subscriptionID
+		updated.Shares = append(updated.Shares, cloneShare(shares[i]))
+	}
+	sort.Slice(updated.Shares, func(i, j int) bool { return updated.Shares[i].UserID < updated.Shares[j].UserID })
+	r.store.subs[subscriptionID] = updated
+
+	logger.Log.Infof("Split of subscription %s set successfully", subscriptionID)
+	return nil
+}
+
+func (r *memorySubscriptionRepo) GetShares(ctx context.Context, subscriptionIDs []uuid.UUID) ([]model.SplitShare, error) {
+	logger.Log.Infof("Getting split shares of %d subscriptions", len(subscriptionIDs))
+	defer r.store.lock(r.inTx)()
+
+	var shares []model.SplitShare
+	for _, id := range subscriptionIDs {
+		if stored, ok := r.store.subs[id]; ok {
+			for _, share := range stored.Shares {
+				shares = append(shares, cloneShare(share))
+			}
+		}
+	}
+	sort.SliceStable(shares, func(i, j int) bool {
+		if shares[i].SubscriptionID != shares[j].SubscriptionID {
+			return bytes.Compare(shares[i].SubscriptionID[:], shares[j].SubscriptionID[:]) < 0
+		}
+		return shares[i].UserID < shares[j].UserID
+	})
+	return shares, nil
+}
+
+// find returns the stored subscriptions matching filter.
+func (r *memorySubscriptionRepo) find(filter model.SubscriptionFilter, now time.Time) []*model.Subscription {
+	var found []*model.Subscription
+	for _, stored := range r.store.subs {
+		if matchesOwner(stored, filter) && matchesFilter(stored, filter, now) {
+			found = append(found, stored)
+		}
+	}
+	return found
+}
+
+// charged returns the subscriptions of the owner in filter that are active
+// between from and to.
+func (r *memorySubscriptionRepo) charged(filter model.SubscriptionFilter, from, to *time.Time) []model.Subscription {
+	var subs []model.Subscription
+	for _, stored := range r.store.subs {
+		if matchesOwner(stored, filter) && activeBetween(stored, from, to) {
+			subs = append(subs, *stored)
+		}
+	}
+	return subs
+}
+
+// matchesOwner is applyOwner for a stored subscription.
+func matchesOwner(sub *model.Subscription, filter model.SubscriptionFilter) bool {
+	if sub.DeletedAt.Valid && !filter.IncludeDeleted {
+		return false
+	}
+	if filter.UserID != "" && sub.UserID != filter.UserID {
+		return false
+	}
+	if filter.GroupID != nil && (sub.GroupID == nil || *sub.GroupID != *filter.GroupID) {
+		return false
+	}
+	if filter.ParticipantID != "" && sub.UserID != filter.ParticipantID {
+		participates := false
+		for _, share := range sub.Shares {
+			participates = participates || share.UserID == filter.ParticipantID
+		}
+		if !participates {
+			return false
+		}
+	}
+	return filter.ServiceName == "" || sub.ServiceName == filter.ServiceName
+}
+
+// matchesFilter is the rest of applyFilter for a stored subscription.
+func matchesFilter(sub *model.Subscription, filter model.SubscriptionFilter, now time.Time) bool {
+	if filter.ServiceNameContains != "" &&
+		!strings.Contains(strings.ToLower(sub.ServiceName), strings.ToLower(filter.ServiceNameContains)) {
+		return false
+	}
+	price := sub.PriceAt(now)
+	if (filter.PriceMin != nil && price < *filter.PriceMin) || (filter.PriceMax != nil && price > *filter.PriceMax) {
+		return false
+	}
+	if filter.ActiveAt != nil &&
+		(sub.StartDate.After(*filter.ActiveAt) || (sub.EndDate != nil && sub.EndDate.Before(*filter.ActiveAt))) {
+		return false
+	}
+	if (filter.StartedFrom != nil && sub.StartDate.Before(*filter.StartedFrom)) ||
+		(filter.StartedTo != nil && sub.StartDate.After(*filter.StartedTo)) {
+		return false
+	}
+	if filter.EndedFrom != nil && (sub.EndDate == nil || sub.EndDate.Before(*filter.EndedFrom)) {
+		return false
+	}
+	return filter.EndedTo == nil || (sub.EndDate != nil && !sub.EndDate.After(*filter.EndedTo))
+}
+
+// sortSubscriptions is applySort for stored subscriptions.
+func sortSubscriptions(subs []*model.Subscription, filter model.SubscriptionFilter, now time.Time) {
+	compare := func(a, b *model.Subscription) int {
+		switch filter.SortBy {
+		case "service_name":
+			return strings.Compare(a.ServiceName, b.ServiceName)
+		case "price":
+			return cmp.Compare(a.PriceAt(now), b.PriceAt(now))
+		case "currency":
+			return strings.Compare(a.Currency, b.Currency)
+		case "billing_period":
+			return strings.Compare(string(a.BillingPeriod), string(b.BillingPeriod))
+		case "end_date":
+			switch {
+			case a.EndDate == nil && b.EndDate == nil:
+				return 0
+			case a.EndDate == nil:
+				return 1
+			case b.EndDate == nil:
+				return -1
+			}
+			return a.EndDate.Compare(*b.EndDate)
+		default:
+			return a.StartDate.Compare(b.StartDate)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		order := compare(subs[i], subs[j])
+		if order == 0 {
+			order = bytes.Compare(subs[i].ID[:], subs[j].ID[:])
+		}
+		if filter.SortDesc {
+			return order > 0
+		}
+		return order < 0
+	})
+}
+
+// compareStart orders sub against the position (start, id) in the
+// (start_date, id) order of cursor pagination.
+func compareStart(sub *model.Subscription, start time.Time, id uuid.UUID) int {
+	if order := sub.StartDate.Compare(start); order != 0 {
+		return order
+	}
+	return bytes.Compare(sub.ID[:], id[:])
+}
+
+// withCurrentPrice returns a copy of the stored sub with the price in effect
+// at now.
+func withCurrentPrice(stored *model.Subscription, now time.Time) *model.Subscription {
+	sub := cloneSubscription(stored)
+	sub.Price = sub.PriceAt(now)
+	return sub
+}
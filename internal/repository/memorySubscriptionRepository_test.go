@@ -0,0 +1,100 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/internal/repository/repotest"
+
+	"github.com/google/uuid"
+)
+
+// knownUsers finds the users of the conformance suite.
+type knownUsers struct {
+	repository.UserRepository
+}
+
+func (knownUsers) GetByID(_ context.Context, id string) (*model.User, error) {
+	if !slices.Contains(repotest.Users, id) {
+		return nil, repository.ErrNotFound
+	}
+	return &model.User{ID: id}, nil
+}
+
+// knownGroups finds the group of the conformance suite.
+type knownGroups struct {
+	repository.GroupRepository
+}
+
+func (knownGroups) GetByID(_ context.Context, id uuid.UUID) (*model.Group, error) {
+	if id != repotest.GroupID {
+		return nil, repository.ErrNotFound
+	}
+	return &model.Group{ID: id, Name: "family"}, nil
+}
+
+func newMemoryStore() *repository.MemoryStore {
+	return repository.NewMemoryStore(knownUsers{}, knownGroups{})
+}
+
+func newMemoryRepo(t *testing.T) repository.SubscriptionRepository {
+	return repository.NewMemorySubscriptionRepository(newMemoryStore())
+}
+
+func TestMemorySubscriptionRepository(t *testing.T) {
+	repotest.Run(t, newMemoryRepo)
+}
+
+func TestMemoryCalcTotalMatchesReference(t *testing.T) {
+	testCalcTotalMatchesReference(t, newMemoryRepo)
+}
+
+func TestMemoryTransactorRollsBackOnPanic(t *testing.T) {
+	store := newMemoryStore()
+	repo := repository.NewMemorySubscriptionRepository(store)
+	audit := repository.NewMemoryAuditRepository(store)
+	ctx := context.Background()
+	sub := &model.Subscription{UserID: repotest.Users[0], ServiceName: "netflix", Price: 29990, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := repo.Create(ctx, sub); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want the panic of the transaction", p)
+			}
+		}()
+		repository.NewMemoryTransactor(store).Transaction(ctx, func(subs repository.SubscriptionRepository, entries repository.AuditRepository) error {
+			if err := subs.Delete(ctx, sub.ID, sub.Version); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if err := entries.Create(ctx, &model.AuditEntry{SubscriptionID: sub.ID, UserID: sub.UserID, Action: model.AuditDelete}); err != nil {
+				t.Fatalf("create audit entry: %v", err)
+			}
+			panic("boom")
+		})
+	}()
+
+	if _, err := repo.GetByID(ctx, sub.ID); err != nil {
+		t.Errorf("GetByID after the panic: %v, want the subscription back", err)
+	}
+	if entries, _, err := audit.GetList(ctx, model.AuditFilter{SubscriptionID: &sub.ID}, 0, 10); err != nil || len(entries) != 0 {
+		t.Errorf("audit entries after the panic = %+v, %v, want none", entries, err)
+	}
+	// The store must be unlocked again.
+	done := make(chan error, 1)
+	go func() { done <- repo.Delete(ctx, sub.ID, sub.Version) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Delete after the panic: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("store is still locked after the panic")
+	}
+}
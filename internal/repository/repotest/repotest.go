@@ -0,0 +1,720 @@
+// Package repotest is the conformance suite every SubscriptionRepository
+// implementation must pass. Call Run from a test of the implementation, with
+// the logger initialised in TestMain as the repositories log through it:
+//
+//	func TestMain(m *testing.M) {
+//		logger.InitLogger()
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestMemorySubscriptionRepository(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repository.SubscriptionRepository {
+//			return repository.NewMemorySubscriptionRepository(repository.NewMemoryStore(users, groups))
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Users and GroupID are the users and the group the suite creates
+// subscriptions for. Implementations that check references must know them.
+var (
+	Users   = []string{"alice", "bob", "carol"}
+	GroupID = uuid.MustParse("6f1b1a4e-3c1f-4c1e-9a57-2b9e2f1d7c10")
+)
+
+// NewRepo returns an empty repository for one test.
+type NewRepo func(t *testing.T) repository.SubscriptionRepository
+
+// Run runs the suite against the repositories returned by newRepo.
+func Run(t *testing.T, newRepo NewRepo) {
+	t.Run("CreateAndGet", func(t *testing.T) { testCreateAndGet(t, newRepo(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo(t)) })
+	t.Run("References", func(t *testing.T) { testReferences(t, newRepo(t)) })
+	t.Run("DeleteRestorePurge", func(t *testing.T) { testDeleteRestorePurge(t, newRepo(t)) })
+	t.Run("GetList", func(t *testing.T) { testGetList(t, newRepo(t)) })
+	t.Run("GetListAfter", func(t *testing.T) { testGetListAfter(t, newRepo(t)) })
+	t.Run("PriceChanges", func(t *testing.T) { testPriceChanges(t, newRepo(t)) })
+	t.Run("Split", func(t *testing.T) { testSplit(t, newRepo(t)) })
+	t.Run("CalcTotal", func(t *testing.T) { testCalcTotal(t, newRepo) })
+	t.Run("CalcMonthly", func(t *testing.T) { testCalcMonthly(t, newRepo(t)) })
+	t.Run("CalcCharges", func(t *testing.T) { testCalcCharges(t, newRepo(t)) })
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+type option func(sub *model.Subscription)
+
+func withEnd(end time.Time) option {
+	return func(sub *model.Subscription) { sub.EndDate = &end }
+}
+
+func withPeriod(period model.BillingPeriod, days uint) option {
+	return func(sub *model.Subscription) { sub.BillingPeriod, sub.BillingPeriodDays = period, days }
+}
+
+func withCurrency(currency string) option {
+	return func(sub *model.Subscription) { sub.Currency = currency }
+}
+
+func withUser(userID string) option {
+	return func(sub *model.Subscription) { sub.UserID = userID }
+}
+
+func create(t *testing.T, repo repository.SubscriptionRepository, service string, price model.Money, start time.Time, options ...option) *model.Subscription {
+	t.Helper()
+	sub := &model.Subscription{
+		UserID:        Users[0],
+		ServiceName:   service,
+		Price:         price,
+		Currency:      model.DefaultCurrency,
+		BillingPeriod: model.BillingMonthly,
+		StartDate:     start,
+	}
+	for _, apply := range options {
+		apply(sub)
+	}
+	if err := repo.Create(context.Background(), sub); err != nil {
+		t.Fatalf("Create(%s): %v", service, err)
+	}
+	return sub
+}
+
+func names(subs []model.Subscription) []string {
+	result := make([]string, len(subs))
+	for i, sub := range subs {
+		result[i] = sub.ServiceName
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateAndGet(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "netflix", 29990, date(2024, 1, 15), withCurrency(""))
+	if sub.ID == uuid.Nil || sub.Version != 1 {
+		t.Fatalf("created subscription has ID %s and version %d, want a new ID and version 1", sub.ID, sub.Version)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.ServiceName != "netflix" || got.Price != 29990 || got.Currency != model.DefaultCurrency ||
+		got.BillingPeriod != model.BillingMonthly || !got.StartDate.Equal(sub.StartDate) || got.EndDate != nil {
+		t.Errorf("GetByID returned %+v", got)
+	}
+	if len(got.PriceChanges) != 1 || got.PriceChanges[0].Price != 29990 || !got.PriceChanges[0].EffectiveFrom.Equal(sub.StartDate) {
+		t.Errorf("price history of a new subscription is %+v, want its starting price", got.PriceChanges)
+	}
+
+	if _, err := repo.GetByID(ctx, uuid.New()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByID of a missing subscription returned %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdate(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "netflix", 29990, date(2024, 1, 15))
+
+	update := *sub
+	update.ServiceName = "netflix premium"
+	update.Price = 1
+	update.EndDate = ptr(date(2024, 12, 31))
+	if err := repo.Update(ctx, &update); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if update.Version != 2 {
+		t.Errorf("version after Update is %d, want 2", update.Version)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.ServiceName != "netflix premium" || got.EndDate == nil || !got.EndDate.Equal(date(2024, 12, 31)) || got.Version != 2 {
+		t.Errorf("GetByID after Update returned %+v", got)
+	}
+	if got.Price != 29990 {
+		t.Errorf("Update changed the price to %s, prices only change through AddPriceChange", got.Price)
+	}
+
+	stale := *sub
+	if err := repo.Update(ctx, &stale); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Errorf("Update at a stale version returned %v, want ErrVersionConflict", err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("failed Update changed the version to %d", stale.Version)
+	}
+
+	if err := repo.BumpVersion(ctx, sub.ID, 1); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Errorf("BumpVersion at a stale version returned %v, want ErrVersionConflict", err)
+	}
+	if err := repo.BumpVersion(ctx, sub.ID, 2); err != nil {
+		t.Fatalf("BumpVersion: %v", err)
+	}
+	if got, err := repo.GetByID(ctx, sub.ID); err != nil || got.Version != 3 || got.ServiceName != "netflix premium" {
+		t.Errorf("GetByID after BumpVersion returned %+v, %v; want version 3", got, err)
+	}
+}
+
+func testReferences(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	unknownGroup := uuid.MustParse("0b7e4a1c-2d3f-4e5a-8b9c-1d2e3f4a5b6c")
+
+	orphan := &model.Subscription{
+		UserID:        "nobody",
+		ServiceName:   "netflix",
+		Price:         29990,
+		Currency:      model.DefaultCurrency,
+		BillingPeriod: model.BillingMonthly,
+		StartDate:     date(2024, 1, 15),
+	}
+	if err := repo.Create(ctx, orphan); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Create for an unknown user returned %v, want ErrConflict", err)
+	}
+	orphan.UserID = Users[0]
+	orphan.GroupID = &unknownGroup
+	if err := repo.Create(ctx, orphan); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Create in an unknown group returned %v, want ErrConflict", err)
+	}
+
+	sub := create(t, repo, "spotify", 16990, date(2024, 1, 15))
+	update := *sub
+	update.UserID = "nobody"
+	if err := repo.Update(ctx, &update); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Update to an unknown user returned %v, want ErrConflict", err)
+	}
+	if err := repo.SetSplit(ctx, sub.ID, model.SplitEqual, []model.SplitShare{{UserID: Users[1]}, {UserID: "nobody"}}); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("SetSplit with an unknown user returned %v, want ErrConflict", err)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.UserID != Users[0] || got.Version != 1 || len(got.Shares) != 0 {
+		t.Errorf("failed writes changed the subscription to %+v", got)
+	}
+	if _, total, err := repo.GetList(ctx, model.SubscriptionFilter{}, 0, 10); err != nil || total != 1 {
+		t.Errorf("GetList after failed creates returned %d subscriptions, %v; want 1", total, err)
+	}
+}
+
+func testDeleteRestorePurge(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "netflix", 29990, date(2024, 1, 15))
+
+	if err := repo.Delete(ctx, sub.ID, 2); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Errorf("Delete at a wrong version returned %v, want ErrVersionConflict", err)
+	}
+	if err := repo.Delete(ctx, sub.ID, 1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, sub.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByID of a deleted subscription returned %v, want ErrNotFound", err)
+	}
+	if deleted, err := repo.GetDeletedByID(ctx, sub.ID); err != nil || !deleted.DeletedAt.Valid {
+		t.Errorf("GetDeletedByID returned %+v, %v", deleted, err)
+	}
+
+	filter := model.SubscriptionFilter{UserID: Users[0]}
+	if _, total, err := repo.GetList(ctx, filter, 0, 10); err != nil || total != 0 {
+		t.Errorf("GetList returned %d subscriptions and %v, want deleted ones left out", total, err)
+	}
+	filter.IncludeDeleted = true
+	if _, total, err := repo.GetList(ctx, filter, 0, 10); err != nil || total != 1 {
+		t.Errorf("GetList with IncludeDeleted returned %d subscriptions and %v, want 1", total, err)
+	}
+	if totals, err := repo.CalcTotal(ctx, model.SubscriptionFilter{UserID: Users[0]}, nil, ptr(date(2024, 3, 31))); err != nil || len(totals) != 0 {
+		t.Errorf("CalcTotal returned %v, %v, want deleted subscriptions left out", totals, err)
+	}
+
+	if err := repo.Restore(ctx, sub.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored, err := repo.GetByID(ctx, sub.ID)
+	if err != nil || restored.Version != 2 {
+		t.Fatalf("GetByID after Restore returned %+v, %v, want version 2", restored, err)
+	}
+	if err := repo.Restore(ctx, sub.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Restore of a subscription that is not deleted returned %v, want ErrNotFound", err)
+	}
+
+	kept := create(t, repo, "spotify", 16990, date(2024, 1, 1))
+	if err := repo.Delete(ctx, kept.ID, 1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Errorf("PurgeDeleted of recent deletions returned %d, %v, want 0", purged, err)
+	}
+	if err := repo.Delete(ctx, sub.ID, 2); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if purged, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Hour)); err != nil || purged != 2 {
+		t.Errorf("PurgeDeleted returned %d, %v, want 2", purged, err)
+	}
+	if _, err := repo.GetDeletedByID(ctx, sub.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetDeletedByID of a purged subscription returned %v, want ErrNotFound", err)
+	}
+}
+
+func testGetList(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	create(t, repo, "alpha", 10000, date(2024, 1, 1), withEnd(date(2024, 6, 30)))
+	create(t, repo, "bravo plus", 30000, date(2024, 2, 1))
+	create(t, repo, "charlie", 20000, date(2024, 3, 1), withEnd(date(2024, 4, 30)))
+	create(t, repo, "100% tv", 5000, date(2024, 4, 1), withEnd(date(2024, 5, 31)))
+	create(t, repo, "delta", 1000, date(2024, 1, 1), withUser(Users[1]))
+
+	tests := []struct {
+		name   string
+		filter model.SubscriptionFilter
+		want   []string
+	}{
+		{"by start date", model.SubscriptionFilter{}, []string{"alpha", "bravo plus", "charlie", "100% tv"}},
+		{"by start date descending", model.SubscriptionFilter{SortDesc: true}, []string{"100% tv", "charlie", "bravo plus", "alpha"}},
+		{"by name", model.SubscriptionFilter{SortBy: "service_name"}, []string{"100% tv", "alpha", "bravo plus", "charlie"}},
+		{"by price", model.SubscriptionFilter{SortBy: "price"}, []string{"100% tv", "alpha", "charlie", "bravo plus"}},
+		{"by end date, open last", model.SubscriptionFilter{SortBy: "end_date"}, []string{"charlie", "100% tv", "alpha", "bravo plus"}},
+		{"by end date descending, open first", model.SubscriptionFilter{SortBy: "end_date", SortDesc: true}, []string{"bravo plus", "alpha", "100% tv", "charlie"}},
+		{"name contains, ignoring case", model.SubscriptionFilter{ServiceNameContains: "PLUS"}, []string{"bravo plus"}},
+		{"name contains a wildcard", model.SubscriptionFilter{ServiceNameContains: "%"}, []string{"100% tv"}},
+		{"exact name", model.SubscriptionFilter{ServiceName: "charlie"}, []string{"charlie"}},
+		{"price range", model.SubscriptionFilter{PriceMin: ptr(model.Money(10000)), PriceMax: ptr(model.Money(20000))}, []string{"alpha", "charlie"}},
+		{"active at", model.SubscriptionFilter{ActiveAt: ptr(date(2024, 5, 15))}, []string{"alpha", "bravo plus", "100% tv"}},
+		{"started between", model.SubscriptionFilter{StartedFrom: ptr(date(2024, 2, 1)), StartedTo: ptr(date(2024, 3, 1))}, []string{"bravo plus", "charlie"}},
+		{"ended between", model.SubscriptionFilter{EndedFrom: ptr(date(2024, 5, 1)), EndedTo: ptr(date(2024, 6, 30))}, []string{"alpha", "100% tv"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.filter.UserID = Users[0]
+			subs, total, err := repo.GetList(ctx, test.filter, 0, 10)
+			if err != nil {
+				t.Fatalf("GetList: %v", err)
+			}
+			if got := names(subs); !equalStrings(got, test.want) || total != int64(len(test.want)) {
+				t.Errorf("GetList returned %q of %d, want %q", got, total, test.want)
+			}
+		})
+	}
+
+	subs, total, err := repo.GetList(ctx, model.SubscriptionFilter{UserID: Users[0]}, 1, 2)
+	if err != nil {
+		t.Fatalf("GetList: %v", err)
+	}
+	if got, want := names(subs), []string{"bravo plus", "charlie"}; !equalStrings(got, want) || total != 4 {
+		t.Errorf("second page of two is %q of %d, want %q of 4", got, total, want)
+	}
+}
+
+func testGetListAfter(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	create(t, repo, "first", 100, date(2024, 1, 1))
+	second := create(t, repo, "second", 100, date(2024, 2, 1))
+	third := create(t, repo, "third", 100, date(2024, 3, 1))
+	filter := model.SubscriptionFilter{UserID: Users[0]}
+
+	subs, hasMore, err := repo.GetListAfter(ctx, filter, nil, 2)
+	if err != nil {
+		t.Fatalf("GetListAfter: %v", err)
+	}
+	if got := names(subs); !equalStrings(got, []string{"first", "second"}) || !hasMore {
+		t.Errorf("first page is %q, more: %t", got, hasMore)
+	}
+
+	subs, hasMore, err = repo.GetListAfter(ctx, filter, &model.Cursor{StartDate: second.StartDate, ID: second.ID}, 2)
+	if err != nil {
+		t.Fatalf("GetListAfter: %v", err)
+	}
+	if got := names(subs); !equalStrings(got, []string{"third"}) || hasMore {
+		t.Errorf("page after the second item is %q, more: %t", got, hasMore)
+	}
+
+	subs, hasMore, err = repo.GetListAfter(ctx, filter, &model.Cursor{StartDate: third.StartDate, ID: third.ID, Backward: true}, 2)
+	if err != nil {
+		t.Fatalf("GetListAfter: %v", err)
+	}
+	if got := names(subs); !equalStrings(got, []string{"first", "second"}) || hasMore {
+		t.Errorf("page before the third item is %q, more: %t", got, hasMore)
+	}
+
+	filter.SortDesc = true
+	subs, hasMore, err = repo.GetListAfter(ctx, filter, &model.Cursor{StartDate: third.StartDate, ID: third.ID, Desc: true}, 1)
+	if err != nil {
+		t.Fatalf("GetListAfter: %v", err)
+	}
+	if got := names(subs); !equalStrings(got, []string{"second"}) || !hasMore {
+		t.Errorf("descending page after the third item is %q, more: %t", got, hasMore)
+	}
+}
+
+func testPriceChanges(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "netflix", 10000, date(2024, 1, 1))
+
+	change := &model.PriceChange{SubscriptionID: sub.ID, Price: 20000, EffectiveFrom: date(2024, 6, 1)}
+	if err := repo.AddPriceChange(ctx, change); err != nil {
+		t.Fatalf("AddPriceChange: %v", err)
+	}
+	change = &model.PriceChange{SubscriptionID: sub.ID, Price: 30000, EffectiveFrom: date(2024, 6, 1)}
+	if err := repo.AddPriceChange(ctx, change); err != nil {
+		t.Fatalf("AddPriceChange replacing a change: %v", err)
+	}
+
+	changes, err := repo.GetPriceChanges(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetPriceChanges: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Price != 10000 || changes[1].Price != 30000 || !changes[1].EffectiveFrom.Equal(date(2024, 6, 1)) {
+		t.Errorf("price history is %+v, want the starting price and 300.00 from 2024-06-01", changes)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Price != 30000 {
+		t.Errorf("current price is %s, want 300.00", got.Price)
+	}
+}
+
+func testSplit(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "netflix", 30000, date(2024, 1, 1))
+	create(t, repo, "spotify", 10000, date(2024, 1, 1))
+
+	shares := []model.SplitShare{{UserID: Users[2]}, {UserID: Users[1]}}
+	if err := repo.SetSplit(ctx, sub.ID, model.SplitEqual, shares); err != nil {
+		t.Fatalf("SetSplit: %v", err)
+	}
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.SplitMethod != model.SplitEqual || len(got.Shares) != 2 || got.Shares[0].UserID != Users[1] || got.Shares[1].UserID != Users[2] {
+		t.Errorf("split is %s between %+v, want equal between %s and %s", got.SplitMethod, got.Shares, Users[1], Users[2])
+	}
+
+	stored, err := repo.GetShares(ctx, []uuid.UUID{sub.ID, uuid.New()})
+	if err != nil || len(stored) != 2 || stored[0].SubscriptionID != sub.ID {
+		t.Errorf("GetShares returned %+v, %v", stored, err)
+	}
+
+	subs, _, err := repo.GetList(ctx, model.SubscriptionFilter{ParticipantID: Users[1]}, 0, 10)
+	if err != nil {
+		t.Fatalf("GetList: %v", err)
+	}
+	if got := names(subs); !equalStrings(got, []string{"netflix"}) {
+		t.Errorf("subscriptions %s takes part in are %q, want [netflix]", Users[1], got)
+	}
+
+	percentages := []model.SplitShare{{UserID: Users[1], Percent: ptr(model.Percent(3333))}}
+	if err := repo.SetSplit(ctx, sub.ID, model.SplitPercentage, percentages); err != nil {
+		t.Fatalf("SetSplit by percentage: %v", err)
+	}
+	if stored, err := repo.GetShares(ctx, []uuid.UUID{sub.ID}); err != nil || len(stored) != 1 || stored[0].Percent == nil || *stored[0].Percent != 3333 {
+		t.Errorf("GetShares after a percentage split returned %+v, %v; want 33.33%%", stored, err)
+	}
+
+	if err := repo.SetSplit(ctx, sub.ID, model.SplitNone, nil); err != nil {
+		t.Fatalf("SetSplit removing the split: %v", err)
+	}
+	if stored, err := repo.GetShares(ctx, []uuid.UUID{sub.ID}); err != nil || len(stored) != 0 {
+		t.Errorf("GetShares after removing the split returned %+v, %v", stored, err)
+	}
+}
+
+// totalCase creates subscriptions in an empty repository and expects their
+// total between from and to.
+type totalCase struct {
+	name     string
+	setup    func(t *testing.T, repo repository.SubscriptionRepository)
+	from, to *time.Time
+	want     map[string]model.Money
+	wantErr  error
+}
+
+func testCalcTotal(t *testing.T, newRepo NewRepo) {
+	tests := []totalCase{
+		{
+			name:  "no subscriptions",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {},
+			want:  map[string]model.Money{},
+		},
+		{
+			name: "monthly without a period bills every month touched",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 15), withEnd(date(2024, 4, 10)))
+			},
+			want: map[string]model.Money{"RUB": 30000},
+		},
+		{
+			name: "monthly ending on its billing day",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 15), withEnd(date(2024, 4, 15)))
+			},
+			want: map[string]model.Money{"RUB": 40000},
+		},
+		{
+			name: "open-ended monthly up to the end of the period",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 1))
+			},
+			to:   ptr(date(2024, 3, 31)),
+			want: map[string]model.Money{"RUB": 30000},
+		},
+		{
+			name: "monthly over a single day is charged once",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 31))
+			},
+			from: ptr(date(2024, 3, 10)),
+			to:   ptr(date(2024, 3, 10)),
+			want: map[string]model.Money{"RUB": 10000},
+		},
+		{
+			name: "period before the start",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 6, 1))
+			},
+			from: ptr(date(2024, 1, 1)),
+			to:   ptr(date(2024, 5, 31)),
+			want: map[string]model.Money{},
+		},
+		{
+			name: "period after the end",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 1), withEnd(date(2024, 2, 1)))
+			},
+			from: ptr(date(2024, 3, 1)),
+			want: map[string]model.Money{},
+		},
+		{
+			name: "period ending before it starts",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 1))
+			},
+			from: ptr(date(2024, 6, 1)),
+			to:   ptr(date(2024, 5, 1)),
+			want: map[string]model.Money{},
+		},
+		{
+			name: "weekly includes both ends",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "gym", 1000, date(2024, 1, 1), withEnd(date(2024, 1, 29)), withPeriod(model.BillingWeekly, 0))
+			},
+			want: map[string]model.Money{"RUB": 5000},
+		},
+		{
+			name: "weekly within a period",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "gym", 1000, date(2024, 1, 1), withPeriod(model.BillingWeekly, 0))
+			},
+			from: ptr(date(2024, 1, 2)),
+			to:   ptr(date(2024, 1, 14)),
+			want: map[string]model.Money{"RUB": 1000},
+		},
+		{
+			name: "weekly period without a charge",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "gym", 1000, date(2024, 1, 1), withPeriod(model.BillingWeekly, 0))
+			},
+			from: ptr(date(2024, 1, 2)),
+			to:   ptr(date(2024, 1, 7)),
+			want: map[string]model.Money{},
+		},
+		{
+			name: "custom period in days",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "vpn", 500, date(2024, 1, 1), withEnd(date(2024, 1, 31)), withPeriod(model.BillingCustom, 10))
+			},
+			want: map[string]model.Money{"RUB": 2000},
+		},
+		{
+			name: "custom period without days bills monthly",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "vpn", 500, date(2024, 1, 1), withEnd(date(2024, 3, 15)), withPeriod(model.BillingCustom, 0))
+			},
+			want: map[string]model.Money{"RUB": 1500},
+		},
+		{
+			name: "quarterly from the end of a month",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "cloud", 10000, date(2024, 1, 31), withPeriod(model.BillingQuarterly, 0))
+			},
+			to:   ptr(date(2024, 12, 31)),
+			want: map[string]model.Money{"RUB": 40000},
+		},
+		{
+			name: "quarterly charge rolled over into the next month",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "cloud", 10000, date(2024, 1, 31), withPeriod(model.BillingQuarterly, 0))
+			},
+			from: ptr(date(2024, 5, 1)),
+			to:   ptr(date(2024, 5, 1)),
+			want: map[string]model.Money{"RUB": 10000},
+		},
+		{
+			name: "yearly within a year",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "domain", 150000, date(2023, 6, 15), withPeriod(model.BillingYearly, 0))
+			},
+			from: ptr(date(2024, 1, 1)),
+			to:   ptr(date(2024, 12, 31)),
+			want: map[string]model.Money{"RUB": 150000},
+		},
+		{
+			name: "yearly from a leap day",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "domain", 150000, date(2024, 2, 29), withPeriod(model.BillingYearly, 0))
+			},
+			from: ptr(date(2025, 3, 1)),
+			to:   ptr(date(2025, 3, 1)),
+			want: map[string]model.Money{"RUB": 150000},
+		},
+		{
+			name: "price changes apply from their date",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				sub := create(t, repo, "netflix", 10000, date(2024, 1, 1), withEnd(date(2024, 4, 30)))
+				change := &model.PriceChange{SubscriptionID: sub.ID, Price: 20000, EffectiveFrom: date(2024, 3, 1)}
+				if err := repo.AddPriceChange(context.Background(), change); err != nil {
+					t.Fatalf("AddPriceChange: %v", err)
+				}
+			},
+			want: map[string]model.Money{"RUB": 60000},
+		},
+		{
+			name: "totals by currency",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 1), withEnd(date(2024, 2, 1)))
+				create(t, repo, "spotify", 999, date(2024, 1, 1), withEnd(date(2024, 1, 31)), withCurrency("USD"))
+				create(t, repo, "youtube", 299, date(2024, 1, 1), withEnd(date(2024, 1, 31)), withCurrency("USD"))
+			},
+			want: map[string]model.Money{"RUB": 20000, "USD": 1298},
+		},
+		{
+			name: "other users' subscriptions are left out",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", 10000, date(2024, 1, 1), withEnd(date(2024, 1, 31)))
+				create(t, repo, "spotify", 20000, date(2024, 1, 1), withEnd(date(2024, 1, 31)), withUser(Users[1]))
+			},
+			want: map[string]model.Money{"RUB": 10000},
+		},
+		{
+			name: "overflow",
+			setup: func(t *testing.T, repo repository.SubscriptionRepository) {
+				create(t, repo, "netflix", math.MaxInt64/2+1, date(2024, 1, 1), withEnd(date(2024, 2, 1)))
+			},
+			wantErr: model.ErrMoneyOverflow,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newRepo(t)
+			test.setup(t, repo)
+			totals, err := repo.CalcTotal(context.Background(), model.SubscriptionFilter{UserID: Users[0]}, test.from, test.to)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("CalcTotal returned %v, %v, want %v", totals, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CalcTotal: %v", err)
+			}
+			if len(totals) != len(test.want) {
+				t.Fatalf("CalcTotal returned %v, want %v", totals, test.want)
+			}
+			for currency, want := range test.want {
+				if totals[currency] != want {
+					t.Errorf("CalcTotal returned %v, want %v", totals, test.want)
+				}
+			}
+		})
+	}
+}
+
+func testCalcMonthly(t *testing.T, repo repository.SubscriptionRepository) {
+	create(t, repo, "music", 10000, date(2024, 1, 15))
+	create(t, repo, "gym", 1000, date(2024, 1, 1), withEnd(date(2024, 2, 29)), withPeriod(model.BillingWeekly, 0))
+	create(t, repo, "later", 1000, date(2024, 6, 1))
+
+	totals, err := repo.CalcMonthly(context.Background(), model.SubscriptionFilter{UserID: Users[0]}, date(2024, 1, 1), date(2024, 2, 29))
+	if err != nil {
+		t.Fatalf("CalcMonthly: %v", err)
+	}
+	want := []model.ServiceMonthTotal{
+		{Month: "2024-01", ServiceName: "gym", Currency: "RUB", Total: 5000},
+		{Month: "2024-01", ServiceName: "music", Currency: "RUB", Total: 10000},
+		{Month: "2024-02", ServiceName: "gym", Currency: "RUB", Total: 4000},
+		{Month: "2024-02", ServiceName: "music", Currency: "RUB", Total: 10000},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("CalcMonthly returned %+v, want %+v", totals, want)
+	}
+	for i := range want {
+		if totals[i] != want[i] {
+			t.Errorf("CalcMonthly returned %+v, want %+v", totals, want)
+			break
+		}
+	}
+}
+
+func testCalcCharges(t *testing.T, repo repository.SubscriptionRepository) {
+	ctx := context.Background()
+	music := create(t, repo, "music", 10000, date(2024, 1, 15), withEnd(date(2024, 3, 20)))
+	gym := create(t, repo, "gym", 1000, date(2024, 1, 1), withEnd(date(2024, 1, 29)), withPeriod(model.BillingWeekly, 0), withCurrency("USD"))
+	if err := repo.SetSplit(ctx, gym.ID, model.SplitEqual, []model.SplitShare{{UserID: Users[1]}}); err != nil {
+		t.Fatalf("SetSplit: %v", err)
+	}
+
+	charges, err := repo.CalcCharges(ctx, model.SubscriptionFilter{UserID: Users[0]}, nil, nil)
+	if err != nil {
+		t.Fatalf("CalcCharges: %v", err)
+	}
+	want := map[uuid.UUID]model.SubscriptionCharges{
+		music.ID: {SubscriptionID: music.ID, UserID: Users[0], Currency: "RUB", Total: 30000, Charges: 3},
+		gym.ID:   {SubscriptionID: gym.ID, UserID: Users[0], SplitMethod: model.SplitEqual, Currency: "USD", Total: 5000, Charges: 5},
+	}
+	if len(charges) != len(want) {
+		t.Fatalf("CalcCharges returned %+v, want %+v", charges, want)
+	}
+	for i, got := range charges {
+		if got != want[got.SubscriptionID] {
+			t.Errorf("CalcCharges returned %+v for subscription %s, want %+v", got, got.SubscriptionID, want[got.SubscriptionID])
+		}
+		if i > 0 && charges[i-1].SubscriptionID.String() >= got.SubscriptionID.String() {
+			t.Errorf("CalcCharges is not ordered by subscription ID")
+		}
+	}
+}
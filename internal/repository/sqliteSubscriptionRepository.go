@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sqliteSubscriptionRepo keeps subscriptions in SQLite. It shares the queries
+// of subscriptionRepo except for charges: SQLite has no date arithmetic like
+// chargesJoinSQL needs, so they are calculated in Go.
+type sqliteSubscriptionRepo struct {
+	*subscriptionRepo
+}
+
+func NewSQLiteSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
+	logger.Log.Info("Creating new SQLite SubscriptionRepository")
+	return &sqliteSubscriptionRepo{subscriptionRepo: &subscriptionRepo{db: db}}
+}
+
+// chargedSubscriptions returns the subscriptions matching filter that are
+// active between from and to, with their price history.
+func (r *sqliteSubscriptionRepo) chargedSubscriptions(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) ([]model.Subscription, error) {
+	var subs []model.Subscription
+	query := applyOwner(r.db.WithContext(ctx).Model(&model.Subscription{}), "subscriptions", filter)
+	err := applyActive(query, from, to).
+		Preload("PriceChanges", orderPriceChanges).
+		Find(&subs).Error
+	return subs, translateError(err)
+}
+
+func (r *sqliteSubscriptionRepo) CalcTotal(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) (map[string]model.Money, error) {
+	logger.Log.Infof("Calculating total subscription cost for %+v, from %v to %v", filter, from, to)
+	subs, err := r.chargedSubscriptions(ctx, filter, from, to)
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription totals: %v", err)
+		return nil, err
+	}
+	totals, err := calcTotal(subs, from, to, time.Now())
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription totals: %v", err)
+		return nil, err
+	}
+	logger.Log.Infof("Total subscription cost calculated: %v", totals)
+	return totals, nil
+}
+
+func (r *sqliteSubscriptionRepo) CalcCharges(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) ([]model.SubscriptionCharges, error) {
+	logger.Log.Infof("Calculating subscription charges for %+v, from %v to %v", filter, from, to)
+	subs, err := r.chargedSubscriptions(ctx, filter, from, to)
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription charges: %v", err)
+		return nil, err
+	}
+	charges, err := calcCharges(subs, from, to, time.Now())
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription charges: %v", err)
+		return nil, err
+	}
+	logger.Log.Infof("Calculated charges of %d subscriptions", len(charges))
+	return charges, nil
+}
+
+func (r *sqliteSubscriptionRepo) CalcMonthly(ctx context.Context, filter model.SubscriptionFilter, from, to time.Time) ([]model.ServiceMonthTotal, error) {
+	logger.Log.Infof("Calculating monthly subscription cost for %+v, from %v to %v", filter, from, to)
+	subs, err := r.chargedSubscriptions(ctx, filter, &from, &to)
+	if err != nil {
+		logger.Log.Errorf("Error calculating monthly subscription costs: %v", err)
+		return nil, err
+	}
+	totals, err := calcMonthly(subs, from, to, time.Now())
+	if err != nil {
+		logger.Log.Errorf("Error calculating monthly subscription costs: %v", err)
+		return nil, err
+	}
+	logger.Log.Infof("Calculated %d monthly service totals", len(totals))
+	return totals, nil
+}
+
+// PurgeDeleted is subscriptionRepo.PurgeDeleted with before in UTC, the zone
+// deletion times are stored in.
+func (r *sqliteSubscriptionRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return r.subscriptionRepo.PurgeDeleted(ctx, before.UTC())
+}
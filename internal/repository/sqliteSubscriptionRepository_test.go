@@ -0,0 +1,35 @@
+//go:build cgo
+
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/internal/repository/repotest"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+)
+
+func newSQLiteRepo(t *testing.T) repository.SubscriptionRepository {
+	db := database.InitSQLite(filepath.Join(t.TempDir(), "subscriptions.db"))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrations.CreateSQLiteSchema(db); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	seed(t, db)
+	return repository.NewSQLiteSubscriptionRepository(db)
+}
+
+func TestSQLiteSubscriptionRepository(t *testing.T) {
+	repotest.Run(t, newSQLiteRepo)
+}
+
+func TestSQLiteCalcTotalMatchesReference(t *testing.T) {
+	testCalcTotalMatchesReference(t, newSQLiteRepo)
+}
@@ -1,21 +1,41 @@
 package repository
 
 import (
+	"context"
+	"strconv"
+	"strings"
+	"subscription-aggregator/internal/apperror"
 	"subscription-aggregator/internal/model"
 	"subscription-aggregator/pkg/logger"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrVersionConflict is returned by Update and Delete when the subscription
+// was changed since the given version was read.
+var ErrVersionConflict = apperror.New(apperror.KindPreconditionFailed, "the subscription has been modified")
+
 type SubscriptionRepository interface {
-	Create(sub *model.Subscription) error
-	GetByID(id uuid.UUID) (*model.Subscription, error)
-	Update(sub *model.Subscription) error
-	Delete(id uuid.UUID) error
-	GetList(filter model.Subscription, offset, limit int) ([]model.Subscription, error)
-	CalcTotal(userID string, serviceName string, from, to *time.Time) (uint, error)
+	Create(ctx context.Context, sub *model.Subscription) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	Update(ctx context.Context, sub *model.Subscription) error
+	BumpVersion(ctx context.Context, id uuid.UUID, version uint) error
+	Delete(ctx context.Context, id uuid.UUID, version uint) error
+	GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	GetList(ctx context.Context, filter model.SubscriptionFilter, offset, limit int) ([]model.Subscription, int64, error)
+	GetListAfter(ctx context.Context, filter model.SubscriptionFilter, cursor *model.Cursor, limit int) ([]model.Subscription, bool, error)
+	CalcTotal(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) (map[string]model.Money, error)
+	CalcMonthly(ctx context.Context, filter model.SubscriptionFilter, from, to time.Time) ([]model.ServiceMonthTotal, error)
+	CalcCharges(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) ([]model.SubscriptionCharges, error)
+	AddPriceChange(ctx context.Context, change *model.PriceChange) error
+	GetPriceChanges(ctx context.Context, subscriptionID uuid.UUID) ([]model.PriceChange, error)
+	SetSplit(ctx context.Context, subscriptionID uuid.UUID, method model.SplitMethod, shares []model.SplitShare) error
+	GetShares(ctx context.Context, subscriptionIDs []uuid.UUID) ([]model.SplitShare, error)
 }
 
 type subscriptionRepo struct {
@@ -27,60 +47,251 @@ func NewSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
 	return &subscriptionRepo{db: db}
 }
 
-func (r *subscriptionRepo) Create(sub *model.Subscription) error {
+func (r *subscriptionRepo) Create(ctx context.Context, sub *model.Subscription) error {
 	logger.Log.Infof("Creating subscription for user %s, service %s", sub.UserID, sub.ServiceName)
-	err := r.db.Create(sub).Error
+	sub.PriceChanges = []model.PriceChange{{Price: sub.Price, EffectiveFrom: sub.StartDate}}
+	sub.Version = 1
+	err := r.db.WithContext(ctx).Create(sub).Error
 	if err != nil {
 		logger.Log.Errorf("Error creating subscription: %v", err)
 	} else {
 		logger.Log.Infof("Subscription created with ID %s", sub.ID)
 	}
-	return err
+	return translateError(err)
 }
 
-func (r *subscriptionRepo) GetByID(id uuid.UUID) (*model.Subscription, error) {
+func (r *subscriptionRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
 	logger.Log.Infof("Getting subscription by ID %s", id)
 	var sub model.Subscription
-	err := r.db.First(&sub, "id = ?", id).Error
+	err := r.db.WithContext(ctx).Preload("PriceChanges", orderPriceChanges).
+		Preload("Shares", orderShares).
+		First(&sub, "id = ?", id).Error
 	if err != nil {
 		logger.Log.Errorf("Subscription with ID %s not found: %v", id, err)
-		return nil, err
+		return nil, translateError(err)
 	}
+	sub.Price = sub.PriceAt(time.Now())
 	logger.Log.Infof("Subscription with ID %s retrieved", id)
 	return &sub, nil
 }
 
-func (r *subscriptionRepo) Update(sub *model.Subscription) error {
-	logger.Log.Infof("Updating subscription with ID %s", sub.ID)
-	err := r.db.Save(sub).Error
-	if err != nil {
-		logger.Log.Errorf("Error updating subscription ID %s: %v", sub.ID, err)
-	} else {
-		logger.Log.Infof("Subscription ID %s updated successfully", sub.ID)
+// Update saves sub if it still has sub.Version in the database and bumps the
+// version on success.
+func (r *subscriptionRepo) Update(ctx context.Context, sub *model.Subscription) error {
+	logger.Log.Infof("Updating subscription with ID %s at version %d", sub.ID, sub.Version)
+	expected := sub.Version
+	sub.Version++
+	// Prices are only changed through AddPriceChange so that past totals stay intact.
+	result := r.db.WithContext(ctx).Model(sub).
+		Where("version = ?", expected).
+		Select("*").
+		Omit("id", "price_minor", clause.Associations).
+		Updates(sub)
+	if result.Error != nil {
+		sub.Version = expected
+		logger.Log.Errorf("Error updating subscription ID %s: %v", sub.ID, result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		sub.Version = expected
+		logger.Log.Warnf("Subscription ID %s is no longer at version %d", sub.ID, expected)
+		return ErrVersionConflict
+	}
+	logger.Log.Infof("Subscription ID %s updated successfully to version %d", sub.ID, sub.Version)
+	return nil
+}
+
+// BumpVersion moves the subscription from version to the next one for a
+// change made outside of Update, such as a new price.
+func (r *subscriptionRepo) BumpVersion(ctx context.Context, id uuid.UUID, version uint) error {
+	logger.Log.Infof("Bumping version of subscription %s from %d", id, version)
+	result := r.db.WithContext(ctx).Model(&model.Subscription{}).
+		Where("id = ? AND version = ?", id, version).
+		Update("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		logger.Log.Errorf("Error bumping version of subscription ID %s: %v", id, result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		logger.Log.Warnf("Subscription ID %s is no longer at version %d", id, version)
+		return ErrVersionConflict
 	}
-	return err
+	logger.Log.Infof("Subscription ID %s is now at version %d", id, version+1)
+	return nil
 }
 
-func (r *subscriptionRepo) Delete(id uuid.UUID) error {
-	logger.Log.Infof("Deleting subscription with ID %s", id)
-	err := r.db.Delete(&model.Subscription{}, "id = ?", id).Error
+// Delete marks the subscription deleted. It stays in the database, left out
+// of lists and totals, until it is restored or purged.
+func (r *subscriptionRepo) Delete(ctx context.Context, id uuid.UUID, version uint) error {
+	logger.Log.Infof("Deleting subscription with ID %s at version %d", id, version)
+	result := r.db.WithContext(ctx).Delete(&model.Subscription{}, "id = ? AND version = ?", id, version)
+	if result.Error != nil {
+		logger.Log.Errorf("Error deleting subscription ID %s: %v", id, result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		logger.Log.Warnf("Subscription ID %s is no longer at version %d", id, version)
+		return ErrVersionConflict
+	}
+	logger.Log.Infof("Subscription ID %s deleted successfully", id)
+	return nil
+}
+
+// GetDeletedByID returns a subscription marked deleted.
+func (r *subscriptionRepo) GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	logger.Log.Infof("Getting deleted subscription by ID %s", id)
+	var sub model.Subscription
+	err := r.db.WithContext(ctx).Unscoped().
+		Preload("PriceChanges", orderPriceChanges).
+		Preload("Shares", orderShares).
+		Where("deleted_at IS NOT NULL").
+		First(&sub, "id = ?", id).Error
 	if err != nil {
-		logger.Log.Errorf("Error deleting subscription ID %s: %v", id, err)
-	} else {
-		logger.Log.Infof("Subscription ID %s deleted successfully", id)
+		logger.Log.Errorf("Deleted subscription with ID %s not found: %v", id, err)
+		return nil, translateError(err)
 	}
-	return err
+	sub.Price = sub.PriceAt(time.Now())
+	return &sub, nil
 }
 
-func (r *subscriptionRepo) GetList(filter model.Subscription, offset, limit int) ([]model.Subscription, error) {
-	logger.Log.Infof("Getting subscriptions list for user %s with offset %d and limit %d", filter.UserID, offset, limit)
+// Restore brings back a deleted subscription. It returns
+// ErrNotFound unless the subscription is marked deleted.
+func (r *subscriptionRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	logger.Log.Infof("Restoring subscription with ID %s", id)
+	result := r.db.WithContext(ctx).Unscoped().Model(&model.Subscription{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		logger.Log.Errorf("Error restoring subscription ID %s: %v", id, result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		logger.Log.Warnf("Subscription ID %s is not deleted", id)
+		return ErrNotFound
+	}
+	logger.Log.Infof("Subscription ID %s restored successfully", id)
+	return nil
+}
+
+// PurgeDeleted permanently removes the subscriptions deleted before the given
+// time, together with their price history and shares.
+func (r *subscriptionRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	logger.Log.Infof("Purging subscriptions deleted before %v", before)
+	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at < ?", before).Delete(&model.Subscription{})
+	if result.Error != nil {
+		logger.Log.Errorf("Error purging deleted subscriptions: %v", result.Error)
+		return 0, translateError(result.Error)
+	}
+	logger.Log.Infof("Purged %d subscriptions", result.RowsAffected)
+	return result.RowsAffected, nil
+}
+
+// currentPriceSQL is the price of a "subscriptions" row in effect right now.
+const currentPriceSQL = `COALESCE((SELECT price_minor FROM price_changes
+	WHERE price_changes.subscription_id = subscriptions.id AND price_changes.effective_from <= now()
+	ORDER BY price_changes.effective_from DESC
+	LIMIT 1), subscriptions.price_minor)`
+
+var sortColumns = map[string]string{
+	"service_name":   "subscriptions.service_name",
+	"price":          currentPriceSQL,
+	"currency":       "subscriptions.currency",
+	"billing_period": "subscriptions.billing_period",
+	"start_date":     "subscriptions.start_date",
+	"end_date":       "subscriptions.end_date",
+}
+
+// nullableSortColumns are sorted with NULLs last in ascending order and first
+// in descending order whatever the database's default is.
+var nullableSortColumns = map[string]bool{"end_date": true}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// applyOwner restricts a query on table to the user, group and service of
+// the filter and, unless the filter includes them, to subscriptions that are
+// not deleted.
+func applyOwner(query *gorm.DB, table string, filter model.SubscriptionFilter) *gorm.DB {
+	// Deleted rows are filtered here rather than by GORM, which only does so
+	// for queries on the model and not on an aliased table.
+	query = query.Unscoped()
+	if !filter.IncludeDeleted {
+		query = query.Where(table + ".deleted_at IS NULL")
+	}
+	if filter.UserID != "" {
+		query = query.Where(table+".user_id = ?", filter.UserID)
+	}
+	if filter.GroupID != nil {
+		query = query.Where(table+".group_id = ?", *filter.GroupID)
+	}
+	if filter.ParticipantID != "" {
+		query = query.Where(table+".user_id = ? OR EXISTS (SELECT 1 FROM split_shares AS ss WHERE ss.subscription_id = "+table+".id AND ss.user_id = ?)",
+			filter.ParticipantID, filter.ParticipantID)
+	}
+	if filter.ServiceName != "" {
+		query = query.Where(table+".service_name = ?", filter.ServiceName)
+	}
+	return query
+}
+
+func applyFilter(query *gorm.DB, filter model.SubscriptionFilter) *gorm.DB {
+	query = applyOwner(query, "subscriptions", filter)
+	if filter.ServiceNameContains != "" {
+		query = query.Where(`LOWER(subscriptions.service_name) LIKE LOWER(?) ESCAPE '\'`, "%"+likeEscaper.Replace(filter.ServiceNameContains)+"%")
+	}
+	if filter.PriceMin != nil {
+		query = query.Where(currentPriceSQL+" >= ?", *filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		query = query.Where(currentPriceSQL+" <= ?", *filter.PriceMax)
+	}
+	if filter.ActiveAt != nil {
+		query = query.Where("subscriptions.start_date <= ? AND (subscriptions.end_date IS NULL OR subscriptions.end_date >= ?)", *filter.ActiveAt, *filter.ActiveAt)
+	}
+	if filter.StartedFrom != nil {
+		query = query.Where("subscriptions.start_date >= ?", *filter.StartedFrom)
+	}
+	if filter.StartedTo != nil {
+		query = query.Where("subscriptions.start_date <= ?", *filter.StartedTo)
+	}
+	if filter.EndedFrom != nil {
+		query = query.Where("subscriptions.end_date >= ?", *filter.EndedFrom)
+	}
+	if filter.EndedTo != nil {
+		query = query.Where("subscriptions.end_date <= ?", *filter.EndedTo)
+	}
+	return query
+}
+
+func applySort(query *gorm.DB, filter model.SubscriptionFilter) *gorm.DB {
+	column, ok := sortColumns[filter.SortBy]
+	if !ok {
+		column = sortColumns["start_date"]
+	}
+	var columns []clause.OrderByColumn
+	if nullableSortColumns[filter.SortBy] {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: column + " IS NULL", Raw: true}, Desc: filter.SortDesc})
+	}
+	columns = append(columns,
+		clause.OrderByColumn{Column: clause.Column{Name: column, Raw: true}, Desc: filter.SortDesc},
+		clause.OrderByColumn{Column: clause.Column{Table: "subscriptions", Name: "id"}, Desc: filter.SortDesc},
+	)
+	return query.Order(clause.OrderBy{Columns: columns})
+}
+
+// GetList returns one page of the subscriptions matching filter along with
+// the number of matching subscriptions.
+func (r *subscriptionRepo) GetList(ctx context.Context, filter model.SubscriptionFilter, offset, limit int) ([]model.Subscription, int64, error) {
+	logger.Log.Infof("Getting subscriptions list with filter %+v, offset %d and limit %d", filter, offset, limit)
 	var subs []model.Subscription
-	query := r.db.Model(&model.Subscription{})
+	query := applyFilter(r.db.WithContext(ctx).Model(&model.Subscription{}), filter)
 
-	if filter.ID.String() != "" {
-		query = query.Where("user_id = ?", filter.UserID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		logger.Log.Errorf("Error counting subscriptions: %v", err)
+		return nil, 0, translateError(err)
 	}
 
+	query = applySort(query, filter)
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -88,40 +299,223 @@ func (r *subscriptionRepo) GetList(filter model.Subscription, offset, limit int)
 		query = query.Offset(offset)
 	}
 
-	err := query.Find(&subs).Error
+	err := query.Preload("PriceChanges", orderPriceChanges).
+		Preload("Shares", orderShares).
+		Find(&subs).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving subscriptions list: %v", err)
+		return nil, 0, translateError(err)
+	}
+
+	now := time.Now()
+	for i := range subs {
+		subs[i].Price = subs[i].PriceAt(now)
+	}
+
+	logger.Log.Infof("Retrieved %d of %d subscriptions", len(subs), total)
+	return subs, total, nil
+}
+
+// GetListAfter returns up to limit subscriptions ordered by (start_date, id)
+// that come after the cursor, or before it for a backward cursor, and
+// whether there are more of them past the returned ones.
+func (r *subscriptionRepo) GetListAfter(ctx context.Context, filter model.SubscriptionFilter, cursor *model.Cursor, limit int) ([]model.Subscription, bool, error) {
+	logger.Log.Infof("Getting subscriptions list with filter %+v after cursor %+v, limit %d", filter, cursor, limit)
+	desc := filter.SortDesc
+	if cursor != nil && cursor.Backward {
+		desc = !desc
+	}
+
+	query := applyFilter(r.db.WithContext(ctx).Model(&model.Subscription{}), filter)
+	if cursor != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		query = query.Where("(subscriptions.start_date, subscriptions.id) "+op+" (?, ?)", cursor.StartDate, cursor.ID)
+	}
+
+	var subs []model.Subscription
+	err := query.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+		{Column: clause.Column{Table: "subscriptions", Name: "start_date"}, Desc: desc},
+		{Column: clause.Column{Table: "subscriptions", Name: "id"}, Desc: desc},
+	}}).
+		Limit(limit+1).
+		Preload("PriceChanges", orderPriceChanges).
+		Preload("Shares", orderShares).
+		Find(&subs).Error
 	if err != nil {
 		logger.Log.Errorf("Error retrieving subscriptions list: %v", err)
-		return nil, err
+		return nil, false, translateError(err)
+	}
+
+	hasMore := len(subs) > limit
+	if hasMore {
+		subs = subs[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		for i, j := 0, len(subs)-1; i < j; i, j = i+1, j-1 {
+			subs[i], subs[j] = subs[j], subs[i]
+		}
+	}
+
+	now := time.Now()
+	for i := range subs {
+		subs[i].Price = subs[i].PriceAt(now)
 	}
 
-	logger.Log.Infof("Retrieved %d subscriptions", len(subs))
-	return subs, nil
+	logger.Log.Infof("Retrieved %d subscriptions, more: %t", len(subs), hasMore)
+	return subs, hasMore, nil
 }
 
-func diffMonths(from, to time.Time) int {
-	yearDiff := to.Year() - from.Year()
-	monthDiff := int(to.Month()) - int(from.Month())
+func orderShares(db *gorm.DB) *gorm.DB {
+	return db.Order("user_id")
+}
 
-	months := yearDiff*12 + monthDiff
+func orderPriceChanges(db *gorm.DB) *gorm.DB {
+	return db.Order("effective_from")
+}
 
-	if to.Day() >= from.Day() {
-		months++
+func (r *subscriptionRepo) AddPriceChange(ctx context.Context, change *model.PriceChange) error {
+	logger.Log.Infof("Setting price %s for subscription %s from %s", change.Price, change.SubscriptionID, change.EffectiveFrom.Format("2006-01-02"))
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "effective_from"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price_minor"}),
+	}).Create(change).Error
+	if err != nil {
+		logger.Log.Errorf("Error setting price for subscription %s: %v", change.SubscriptionID, err)
+	} else {
+		logger.Log.Infof("Price for subscription %s set successfully", change.SubscriptionID)
 	}
+	return translateError(err)
+}
 
-	logger.Log.Debugf("diffMonths: from %s to %s = %d months", from.Format("2006-01-02"), to.Format("2006-01-02"), months)
-	return months
+func (r *subscriptionRepo) GetPriceChanges(ctx context.Context, subscriptionID uuid.UUID) ([]model.PriceChange, error) {
+	logger.Log.Infof("Getting price history of subscription %s", subscriptionID)
+	var changes []model.PriceChange
+	err := orderPriceChanges(r.db.WithContext(ctx)).Find(&changes, "subscription_id = ?", subscriptionID).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving price history of subscription %s: %v", subscriptionID, err)
+		return nil, translateError(err)
+	}
+	logger.Log.Infof("Retrieved %d price changes", len(changes))
+	return changes, nil
 }
 
-func (r *subscriptionRepo) CalcTotal(userID string, serviceName string, from, to *time.Time) (uint, error) {
-	logger.Log.Infof("Calculating total subscription cost for user %s, service %s, from %v to %v", userID, serviceName, from, to)
-	var subs []model.Subscription
+// billingStepSQL describes the billing period of a subscription row either in
+// days (weekly, custom) or in months (quarterly, yearly). Monthly and unknown
+// periods have neither.
+const billingStepSQL = `CASE WHEN billing_period = 'weekly' THEN 7
+		WHEN billing_period = 'custom' AND billing_period_days > 0 THEN billing_period_days END AS step_days,
+	CASE billing_period WHEN 'quarterly' THEN 3 WHEN 'yearly' THEN 12 END AS step_months`
+
+// chargeWindowSQL clamps every matching subscription to the requested period.
+// All dates are taken in UTC so that day and month arithmetic does not depend
+// on the session time zone.
+const chargeWindowSQL = `id, COALESCE(NULLIF(currency, ''), ?) AS currency, price_minor, ` + billingStepSQL + `,
+	start_date AT TIME ZONE 'UTC' AS anchor,
+	GREATEST(start_date, ?) AT TIME ZONE 'UTC' AS period_start,
+	LEAST(COALESCE(end_date, now()), ?) AT TIME ZONE 'UTC' AS period_end`
 
-	query := r.db.Model(&model.Subscription{}).Where("user_id = ?", userID)
+// chargesJoinSQL expands a clamped subscription "s" into one row per charge
+// date "c.charge_date" and the price change "pp" in effect on that date:
+//   - monthly (and any unknown period) bills every calendar month the window
+//     touches: months between the two dates, plus one when the end day is not
+//     earlier than the start day, at least one. The charges fall on the
+//     window's start day of every month;
+//   - weekly and custom periods bill every N days from the start date;
+//   - quarterly and yearly bill on the start date's day of month every 3 or
+//     12 months. A day that does not exist in the target month rolls over into
+//     the next one (Jan 31 + 1 month = Mar 3), same as time.AddDate.
+const chargesJoinSQL = `CROSS JOIN LATERAL generate_series(
+		CASE WHEN step_days IS NOT NULL
+			THEN CEIL(EXTRACT(EPOCH FROM period_start - anchor) / (86400 * step_days))
+			ELSE 0
+		END::bigint,
+		CASE
+			WHEN step_days IS NOT NULL THEN FLOOR(EXTRACT(EPOCH FROM period_end - anchor) / (86400 * step_days))
+			WHEN step_months IS NOT NULL THEN FLOOR(((EXTRACT(YEAR FROM period_end) - EXTRACT(YEAR FROM anchor)) * 12
+				+ EXTRACT(MONTH FROM period_end) - EXTRACT(MONTH FROM anchor)) / step_months)
+			ELSE GREATEST(1,
+				(EXTRACT(YEAR FROM period_end) - EXTRACT(YEAR FROM period_start)) * 12
+				+ EXTRACT(MONTH FROM period_end) - EXTRACT(MONTH FROM period_start)
+				+ CASE WHEN EXTRACT(DAY FROM period_end) >= EXTRACT(DAY FROM period_start) THEN 1 ELSE 0 END) - 1
+		END::bigint) AS n
+	CROSS JOIN LATERAL (SELECT CASE
+		WHEN step_days IS NOT NULL THEN anchor + n * step_days * interval '1 day'
+		WHEN step_months IS NOT NULL THEN date_trunc('month', anchor)
+			+ make_interval(months => (n * step_months)::int)
+			+ (anchor - date_trunc('month', anchor))
+		ELSE date_trunc('month', period_start) + make_interval(months => n::int)
+			+ (LEAST(EXTRACT(DAY FROM period_start),
+				EXTRACT(DAY FROM date_trunc('month', period_start) + make_interval(months => n::int + 1) - interval '1 day')) - 1)
+				* interval '1 day'
+	END AS charge_date) AS c
+	LEFT JOIN LATERAL (SELECT price_minor FROM price_changes
+		WHERE subscription_id = s.id AND effective_from AT TIME ZONE 'UTC' <= c.charge_date
+		ORDER BY effective_from DESC
+		LIMIT 1) AS pp ON true`
 
-	if serviceName != "" {
-		query = query.Where("service_name = ?", serviceName)
+// chargesFilterSQL drops empty windows and, for periods with fixed charge
+// dates, the charges outside of the window.
+const chargesFilterSQL = `s.period_end >= s.period_start
+	AND ((s.step_days IS NULL AND s.step_months IS NULL) OR c.charge_date BETWEEN s.period_start AND s.period_end)`
+
+// chargesSumSQL adds up the charges at the price in effect on each charge date.
+const chargesSumSQL = `ROUND(SUM(COALESCE(pp.price_minor, s.price_minor)::numeric))::text AS total`
+
+func (r *subscriptionRepo) CalcTotal(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) (map[string]model.Money, error) {
+	logger.Log.Infof("Calculating total subscription cost for %+v, from %v to %v", filter, from, to)
+
+	query := r.chargeWindows(filter, from, to, "")
+
+	var rows []struct {
+		Currency string
+		Total    string
+	}
+	err := r.db.WithContext(ctx).Table("(?) AS s", query).
+		Select("s.currency, " + chargesSumSQL).
+		Joins(chargesJoinSQL).
+		Where(chargesFilterSQL).
+		Group("s.currency").
+		Scan(&rows).Error
+	if err != nil {
+		logger.Log.Errorf("Error calculating subscription totals: %v", err)
+		return nil, translateError(err)
 	}
 
+	totals := make(map[string]model.Money, len(rows))
+	for _, row := range rows {
+		if totals[row.Currency], err = parseTotal(row.Total); err != nil {
+			logger.Log.Errorf("Total in %s is out of range: %s", row.Currency, row.Total)
+			return nil, err
+		}
+	}
+
+	logger.Log.Infof("Total subscription cost calculated: %v", totals)
+	return totals, nil
+}
+
+// chargeWindows selects the subscriptions matching filter that are active
+// between from and to (both optional), clamped to that period, together with
+// extra columns.
+func (r *subscriptionRepo) chargeWindows(filter model.SubscriptionFilter, from, to *time.Time, columns string) *gorm.DB {
+	var fromArg, toArg interface{}
+	if from != nil {
+		fromArg = *from
+	}
+	if to != nil {
+		toArg = *to
+	}
+
+	query := applyOwner(r.db.Model(&model.Subscription{}), "subscriptions", filter).
+		Select(chargeWindowSQL+columns, model.DefaultCurrency, fromArg, toArg)
+	return applyActive(query, from, to)
+}
+
+// applyActive restricts a query on subscriptions to those active between
+// from and to, both optional.
+func applyActive(query *gorm.DB, from, to *time.Time) *gorm.DB {
 	if from != nil && to != nil {
 		query = query.Where(`
 			start_date <= ? AND (end_date IS NULL OR end_date >= ?)`,
@@ -132,44 +526,159 @@ func (r *subscriptionRepo) CalcTotal(userID string, serviceName string, from, to
 	} else if to != nil {
 		query = query.Where("start_date <= ?", *to)
 	}
+	return query
+}
 
-	err := query.Find(&subs).Error
+// CalcCharges returns, for every subscription matching filter, the sum and the
+// number of its charges between from and to.
+func (r *subscriptionRepo) CalcCharges(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) ([]model.SubscriptionCharges, error) {
+	logger.Log.Infof("Calculating subscription charges for %+v, from %v to %v", filter, from, to)
+
+	var rows []struct {
+		ID          uuid.UUID
+		UserID      string
+		SplitMethod model.SplitMethod
+		Currency    string
+		Total       string
+		Charges     int64
+	}
+	err := r.db.WithContext(ctx).Table("(?) AS s", r.chargeWindows(filter, from, to, ", user_id, split_method")).
+		Select("s.id, s.user_id, s.split_method, s.currency, COUNT(*) AS charges, " + chargesSumSQL).
+		Joins(chargesJoinSQL).
+		Where(chargesFilterSQL).
+		Group("s.id, s.user_id, s.split_method, s.currency").
+		Order("s.id").
+		Scan(&rows).Error
 	if err != nil {
-		logger.Log.Errorf("Error querying subscriptions for total calculation: %v", err)
-		return 0, err
+		logger.Log.Errorf("Error calculating subscription charges: %v", err)
+		return nil, translateError(err)
 	}
 
-	logger.Log.Infof("Found %d subscriptions to process for total calculation", len(subs))
-
-	var total uint
-	for _, sub := range subs {
-		start := sub.StartDate
-		end := time.Now()
-		if sub.EndDate != nil {
-			end = *sub.EndDate
+	charges := make([]model.SubscriptionCharges, 0, len(rows))
+	for _, row := range rows {
+		total, err := parseTotal(row.Total)
+		if err != nil {
+			logger.Log.Errorf("Charges of subscription %s are out of range: %s", row.ID, row.Total)
+			return nil, err
 		}
+		charges = append(charges, model.SubscriptionCharges{
+			SubscriptionID: row.ID,
+			UserID:         row.UserID,
+			SplitMethod:    row.SplitMethod,
+			Currency:       row.Currency,
+			Total:          total,
+			Charges:        row.Charges,
+		})
+	}
+
+	logger.Log.Infof("Calculated charges of %d subscriptions", len(charges))
+	return charges, nil
+}
 
-		if from != nil && start.Before(*from) {
-			start = *from
+// SetSplit replaces the split of a subscription. An empty method with no
+// shares removes it.
+func (r *subscriptionRepo) SetSplit(ctx context.Context, subscriptionID uuid.UUID, method model.SplitMethod, shares []model.SplitShare) error {
+	logger.Log.Infof("Setting %s split of subscription %s between %d users", method, subscriptionID, len(shares))
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Subscription{}).Where("id = ?", subscriptionID).Update("split_method", method).Error; err != nil {
+			return err
 		}
-		if to != nil && end.After(*to) {
-			end = *to
+		if err := tx.Where("subscription_id = ?", subscriptionID).Delete(&model.SplitShare{}).Error; err != nil {
+			return err
 		}
-
-		if end.Before(start) {
-			logger.Log.Warnf("Subscription ID %s: end date %s before start date %s after filter adjustment, skipping", sub.ID, end, start)
-			continue
+		if len(shares) == 0 {
+			return nil
 		}
-
-		months := diffMonths(start, end)
-		if months == 0 {
-			months = 1
+		for i := range shares {
+			shares[i].SubscriptionID = subscriptionID
 		}
+		return tx.Create(&shares).Error
+	})
+	if err != nil {
+		logger.Log.Errorf("Error setting split of subscription %s: %v", subscriptionID, err)
+	} else {
+		logger.Log.Infof("Split of subscription %s set successfully", subscriptionID)
+	}
+	return translateError(err)
+}
+
+func (r *subscriptionRepo) GetShares(ctx context.Context, subscriptionIDs []uuid.UUID) ([]model.SplitShare, error) {
+	logger.Log.Infof("Getting split shares of %d subscriptions", len(subscriptionIDs))
+	var shares []model.SplitShare
+	if len(subscriptionIDs) == 0 {
+		return shares, nil
+	}
+	err := r.db.WithContext(ctx).Where("subscription_id IN ?", subscriptionIDs).Order("subscription_id, user_id").Find(&shares).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving split shares: %v", err)
+		return nil, translateError(err)
+	}
+	return shares, nil
+}
 
-		logger.Log.Debugf("Subscription ID %s: price %d x months %d = %d", sub.ID, sub.Price, months, sub.Price*uint(months))
-		total += sub.Price * uint(months)
+// CalcMonthly splits the charges between from and to by calendar month and
+// service. A monthly subscription is charged once for every month it is
+// active in, other periods in the month their charge date falls into.
+func (r *subscriptionRepo) CalcMonthly(ctx context.Context, filter model.SubscriptionFilter, from, to time.Time) ([]model.ServiceMonthTotal, error) {
+	logger.Log.Infof("Calculating monthly subscription cost for %+v, from %v to %v", filter, from, to)
+
+	query := r.db.WithContext(ctx).Table("subscriptions AS sub").
+		Select(`to_char(m.month, 'YYYY-MM') AS month, sub.id, sub.service_name,
+			COALESCE(NULLIF(sub.currency, ''), ?) AS currency, sub.price_minor,
+			`+billingStepSQL+`,
+			sub.start_date AT TIME ZONE 'UTC' AS anchor,
+			GREATEST(sub.start_date AT TIME ZONE 'UTC', m.month, ?::timestamptz AT TIME ZONE 'UTC') AS period_start,
+			LEAST(COALESCE(sub.end_date, now()) AT TIME ZONE 'UTC',
+				m.month + interval '1 month' - interval '1 microsecond',
+				?::timestamptz AT TIME ZONE 'UTC') AS period_end`, model.DefaultCurrency, from, to).
+		Joins(`JOIN generate_series(
+			date_trunc('month', ?::timestamptz AT TIME ZONE 'UTC'),
+			?::timestamptz AT TIME ZONE 'UTC',
+			interval '1 month') AS m(month) ON true`, from, to).
+		Where("sub.start_date <= ? AND (sub.end_date IS NULL OR sub.end_date >= ?)", to, from)
+	query = applyOwner(query, "sub", filter)
+
+	var rows []struct {
+		Month       string
+		ServiceName string
+		Currency    string
+		Total       string
+	}
+	err := r.db.WithContext(ctx).Table("(?) AS s", query).
+		Select("s.month, s.service_name, s.currency, " + chargesSumSQL).
+		Joins(chargesJoinSQL).
+		Where(chargesFilterSQL).
+		Group("s.month, s.service_name, s.currency").
+		Order("s.month, s.service_name, s.currency").
+		Scan(&rows).Error
+	if err != nil {
+		logger.Log.Errorf("Error calculating monthly subscription costs: %v", err)
+		return nil, translateError(err)
 	}
 
-	logger.Log.Infof("Total subscription cost calculated: %d", total)
-	return total, nil
+	totals := make([]model.ServiceMonthTotal, 0, len(rows))
+	for _, row := range rows {
+		total, err := parseTotal(row.Total)
+		if err != nil {
+			logger.Log.Errorf("Total for %s in %s is out of range: %s", row.ServiceName, row.Month, row.Total)
+			return nil, err
+		}
+		totals = append(totals, model.ServiceMonthTotal{
+			Month:       row.Month,
+			ServiceName: row.ServiceName,
+			Currency:    row.Currency,
+			Total:       total,
+		})
+	}
+
+	logger.Log.Infof("Calculated %d monthly service totals", len(totals))
+	return totals, nil
+}
+
+func parseTotal(total string) (model.Money, error) {
+	minor, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, model.ErrMoneyOverflow
+	}
+	return model.Money(minor), nil
 }
@@ -0,0 +1,118 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/internal/repository/repotest"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// postgresDSNEnv names the variable with the DSN of a PostgreSQL database the
+// tests may wipe. Tests needing PostgreSQL are skipped without it.
+const postgresDSNEnv = "SUBAGG_TEST_POSTGRES_DSN"
+
+var postgres struct {
+	once sync.Once
+	db   *gorm.DB
+	err  error
+}
+
+// openPostgres returns the test database, migrated and emptied except for the
+// users and the group of the conformance suite.
+func openPostgres(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	postgres.once.Do(func() {
+		postgres.db = database.InitDB(dsn)
+		migrator, err := migrations.NewMigrator(postgres.db)
+		if err == nil {
+			_, err = migrator.Up()
+		}
+		postgres.err = err
+	})
+	if postgres.err != nil {
+		t.Fatalf("migrate test database: %v", postgres.err)
+	}
+
+	db := postgres.db
+	err := db.Exec(`TRUNCATE audit_entries, split_shares, price_changes, subscriptions,
+		api_keys, group_members, groups, users CASCADE`).Error
+	if err != nil {
+		t.Fatalf("empty test database: %v", err)
+	}
+	seed(t, db)
+	return db
+}
+
+func newPostgresRepo(t *testing.T) repository.SubscriptionRepository {
+	return repository.NewSubscriptionRepository(openPostgres(t))
+}
+
+func TestPostgresSubscriptionRepository(t *testing.T) {
+	openPostgres(t)
+	repotest.Run(t, newPostgresRepo)
+}
+
+// TestPostgresCalcTotalMatchesReference checks the SQL calculation of
+// chargesJoinSQL against the Go one it replaced.
+func TestPostgresCalcTotalMatchesReference(t *testing.T) {
+	openPostgres(t)
+	testCalcTotalMatchesReference(t, newPostgresRepo)
+}
+
+// BenchmarkPostgresCalcTotal compares CalcTotal in SQL with loading every
+// subscription of the user and adding the charges up in Go, the way CalcTotal
+// worked before.
+func BenchmarkPostgresCalcTotal(b *testing.B) {
+	periods := []model.BillingPeriod{model.BillingMonthly, model.BillingWeekly, model.BillingQuarterly, model.BillingYearly, model.BillingCustom}
+	from, to := date(2022, 1, 1), date(2025, 12, 31)
+	filter := model.SubscriptionFilter{UserID: repotest.Users[0]}
+
+	for _, count := range []int{100, 1000, 10000} {
+		db := openPostgres(b)
+		subs := make([]model.Subscription, count)
+		for i := range subs {
+			var end *time.Time
+			if i%2 == 0 {
+				end = ptr(date(2024, time.Month(1+i%12), 1+i%28))
+			}
+			subs[i] = subscription(model.Money(100+i), periods[i%len(periods)], date(2020, 1, 1).AddDate(0, 0, i%1500), end)
+		}
+		if err := db.CreateInBatches(&subs, 500).Error; err != nil {
+			b.Fatalf("create subscriptions: %v", err)
+		}
+		repo := repository.NewSubscriptionRepository(db)
+
+		b.Run(fmt.Sprintf("sql/%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.CalcTotal(context.Background(), filter, &from, &to); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("go/%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var loaded []model.Subscription
+				if err := db.Where("user_id = ?", filter.UserID).Find(&loaded).Error; err != nil {
+					b.Fatal(err)
+				}
+				if _, err := referenceTotal(loaded, &from, &to, time.Now()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
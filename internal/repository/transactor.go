@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"subscription-aggregator/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Transactor runs fn in one database transaction, passing repositories bound
+// to it. An error from fn rolls back everything fn wrote.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(subs SubscriptionRepository, audit AuditRepository) error) error
+}
+
+type gormTransactor struct {
+	db   *gorm.DB
+	subs func(tx *gorm.DB) SubscriptionRepository
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	logger.Log.Info("Creating new Transactor")
+	return &gormTransactor{db: db, subs: func(tx *gorm.DB) SubscriptionRepository {
+		return &subscriptionRepo{db: tx}
+	}}
+}
+
+// NewSQLiteTransactor is NewTransactor for a SQLite database.
+func NewSQLiteTransactor(db *gorm.DB) Transactor {
+	logger.Log.Info("Creating new SQLite Transactor")
+	return &gormTransactor{db: db, subs: func(tx *gorm.DB) SubscriptionRepository {
+		return &sqliteSubscriptionRepo{subscriptionRepo: &subscriptionRepo{db: tx}}
+	}}
+}
+
+func (t *gormTransactor) Transaction(ctx context.Context, fn func(subs SubscriptionRepository, audit AuditRepository) error) error {
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(t.subs(tx), &auditRepo{db: tx})
+	})
+	return translateError(err)
+}
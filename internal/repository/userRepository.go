@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *model.User) error
+	GetByID(ctx context.Context, id string) (*model.User, error)
+	GetList(ctx context.Context, offset, limit int) ([]model.User, int64, error)
+	Delete(ctx context.Context, id string) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+type userRepo struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	logger.Log.Info("Creating new UserRepository")
+	return &userRepo{db: db}
+}
+
+func (r *userRepo) Create(ctx context.Context, user *model.User) error {
+	logger.Log.Infof("Creating user: %+v", user)
+	err := r.db.WithContext(ctx).Create(user).Error
+	if err != nil {
+		logger.Log.Errorf("Error creating user: %v", err)
+	} else {
+		logger.Log.Infof("User created successfully: %s", user.ID)
+	}
+	return translateError(err)
+}
+
+func (r *userRepo) GetByID(ctx context.Context, id string) (*model.User, error) {
+	logger.Log.Infof("Getting user by ID: %s", id)
+	var user model.User
+	err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error
+	if err != nil {
+		logger.Log.Errorf("User with ID %s not found: %v", id, err)
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepo) GetList(ctx context.Context, offset, limit int) ([]model.User, int64, error) {
+	logger.Log.Infof("Getting users list with offset %d, limit %d", offset, limit)
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&model.User{}).Count(&total).Error; err != nil {
+		logger.Log.Errorf("Error counting users: %v", err)
+		return nil, 0, translateError(err)
+	}
+
+	var users []model.User
+	err := r.db.WithContext(ctx).Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		logger.Log.Errorf("Error retrieving users list: %v", err)
+		return nil, 0, translateError(err)
+	}
+	logger.Log.Infof("Retrieved %d of %d users", len(users), total)
+	return users, total, nil
+}
+
+// Delete marks the user deleted, revokes their API keys and removes them
+// from their groups. It returns ErrNotFound when there is no such user.
+func (r *userRepo) Delete(ctx context.Context, id string) error {
+	logger.Log.Infof("Deleting user with ID: %s", id)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.User{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		err := tx.Model(&model.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&model.GroupMember{}, "user_id = ?", id).Error
+	})
+	if errors.Is(err, ErrNotFound) {
+		logger.Log.Warnf("User %s not found", id)
+		return err
+	}
+	if err != nil {
+		logger.Log.Errorf("Error deleting user %s: %v", id, err)
+		return translateError(err)
+	}
+	logger.Log.Infof("User %s deleted successfully", id)
+	return nil
+}
+
+// PurgeDeleted permanently removes the users deleted before the given time
+// that have no subscriptions left, deleted ones included.
+func (r *userRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	logger.Log.Infof("Purging users deleted before %v", before)
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM subscriptions WHERE subscriptions.user_id = users.id)").
+		Delete(&model.User{})
+	if result.Error != nil {
+		logger.Log.Errorf("Error purging deleted users: %v", result.Error)
+		return 0, translateError(result.Error)
+	}
+	logger.Log.Infof("Purged %d users", result.RowsAffected)
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyPrefix = "sa_"
+	// apiKeyTouchInterval limits how often the last use of a key is written.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey   = apperror.Unauthorized("invalid API key")
+	ErrAPIKeyNotFound  = apperror.NotFound("API key not found")
+	ErrInvalidAPIScope = apperror.Validation("invalid API key scope", nil)
+)
+
+type APIKeyService interface {
+	Issue(ctx context.Context, userID, name string, scope model.APIKeyScope) (*model.IssuedAPIKey, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error)
+	GetByUser(ctx context.Context, userID string) ([]model.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	Authenticate(ctx context.Context, secret string) (*model.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	logger.Log.Info("Creating new APIKeyService")
+	return &apiKeyService{repo: repo}
+}
+
+// Issue creates a key with a random 256-bit secret. The secret is only
+// returned here.
+func (s *apiKeyService) Issue(ctx context.Context, userID, name string, scope model.APIKeyScope) (*model.IssuedAPIKey, error) {
+	logger.Log.Infof("Service: issuing %s API key '%s' for user %s", scope, name, userID)
+	if !scope.Valid() {
+		return nil, ErrInvalidAPIScope.Detailf("%q", scope)
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("generate API key: %w", err)
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key := model.IssuedAPIKey{
+		APIKey: model.APIKey{
+			UserID: userID,
+			Name:   name,
+			Prefix: secret[:len(apiKeyPrefix)+8],
+			Hash:   hashAPIKey(secret),
+			Scope:  scope,
+		},
+		Secret: secret,
+	}
+	if err := s.repo.Create(ctx, &key.APIKey); err != nil {
+		logger.Log.Errorf("Service: error issuing API key: %v", err)
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *apiKeyService) GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error) {
+	logger.Log.Infof("Service: getting API key %s", id)
+	key, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound.Detailf("%s", id)
+	}
+	return key, err
+}
+
+func (s *apiKeyService) GetByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	logger.Log.Infof("Service: getting API keys of user %s", userID)
+	keys, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting API keys: %v", err)
+	}
+	return keys, err
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id uuid.UUID) error {
+	logger.Log.Infof("Service: revoking API key %s", id)
+	err := s.repo.Revoke(ctx, id, time.Now().UTC())
+	if err != nil {
+		logger.Log.Errorf("Service: error revoking API key %s: %v", id, err)
+	}
+	return err
+}
+
+// Authenticate returns the active key with the given secret and records its
+// use.
+func (s *apiKeyService) Authenticate(ctx context.Context, secret string) (*model.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.repo.GetByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		logger.Log.Errorf("Service: error looking up API key: %v", err)
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		logger.Log.Warnf("Service: revoked API key %s used", key.Prefix)
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if err := s.repo.TouchLastUsed(ctx, key.ID, now, now.Add(-apiKeyTouchInterval)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+//go:build cgo
+
+package service_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/internal/service"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+)
+
+func newAPIKeyService(t *testing.T) service.APIKeyService {
+	t.Helper()
+	db := database.InitSQLite(filepath.Join(t.TempDir(), "keys.db"))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrations.CreateSQLiteSchema(db); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	if err := db.Create(&model.User{ID: "alice"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return service.NewAPIKeyService(repository.NewAPIKeyRepository(db))
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   model.APIKeyScope
+		revoke  bool
+		secret  func(issued string) string
+		wantErr error
+	}{
+		{name: "read-only key", scope: model.ScopeReadOnly, secret: func(issued string) string { return issued }},
+		{name: "admin key", scope: model.ScopeAdmin, secret: func(issued string) string { return issued }},
+		{name: "revoked key", scope: model.ScopeReadWrite, revoke: true, secret: func(issued string) string { return issued }, wantErr: service.ErrInvalidAPIKey},
+		{name: "unknown secret", scope: model.ScopeReadWrite, secret: func(issued string) string { return issued[:len(issued)-1] + "x" }, wantErr: service.ErrInvalidAPIKey},
+		{name: "secret without prefix", scope: model.ScopeReadWrite, secret: func(issued string) string { return issued[len("sa_"):] }, wantErr: service.ErrInvalidAPIKey},
+		{name: "empty secret", scope: model.ScopeReadWrite, secret: func(string) string { return "" }, wantErr: service.ErrInvalidAPIKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := newAPIKeyService(t)
+			ctx := context.Background()
+			issued, err := keys.Issue(ctx, "alice", "ci", test.scope)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			if test.revoke {
+				if err := keys.Revoke(ctx, issued.ID); err != nil {
+					t.Fatalf("Revoke: %v", err)
+				}
+			}
+
+			key, err := keys.Authenticate(ctx, test.secret(issued.Secret))
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Authenticate = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if key.ID != issued.ID || key.UserID != "alice" || key.Scope != test.scope {
+				t.Errorf("Authenticate = %+v, want key %s of alice with scope %s", key, issued.ID, test.scope)
+			}
+			stored, err := keys.GetByID(ctx, issued.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if stored.LastUsedAt == nil {
+				t.Error("last use of the key is not recorded")
+			}
+		})
+	}
+}
+
+func TestIssueAPIKey(t *testing.T) {
+	keys := newAPIKeyService(t)
+	ctx := context.Background()
+	if _, err := keys.Issue(ctx, "alice", "ci", "owner"); !errors.Is(err, service.ErrInvalidAPIScope) {
+		t.Errorf("Issue with an unknown scope = %v, want %v", err, service.ErrInvalidAPIScope)
+	}
+
+	issued, err := keys.Issue(ctx, "alice", "ci", model.ScopeReadWrite)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	stored, err := keys.GetByID(ctx, issued.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.Hash == "" || stored.Hash == issued.Secret || stored.Prefix != issued.Secret[:len(stored.Prefix)] {
+		t.Errorf("stored key = %+v, want the hash and prefix of the secret", stored)
+	}
+}
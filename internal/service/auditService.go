@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/pkg/logger"
+)
+
+type AuditService interface {
+	GetList(ctx context.Context, filter model.AuditFilter, offset, limit int) ([]model.AuditEntry, int64, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	logger.Log.Info("Creating new AuditService")
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) GetList(ctx context.Context, filter model.AuditFilter, offset, limit int) ([]model.AuditEntry, int64, error) {
+	logger.Log.Infof("Service: getting audit entries for %+v with offset %d and limit %d", filter, offset, limit)
+	entries, total, err := s.repo.GetList(ctx, filter, offset, limit)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting audit entries: %v", err)
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// recordChange appends a change of sub to the audit log.
+func recordChange(ctx context.Context, audit repository.AuditRepository, actor model.Actor, action model.AuditAction, sub *model.Subscription, changes model.AuditChanges) error {
+	return audit.Create(ctx, &model.AuditEntry{
+		SubscriptionID: sub.ID,
+		UserID:         sub.UserID,
+		Action:         action,
+		Actor:          actor.UserID,
+		RequestID:      actor.RequestID,
+		Changes:        changes,
+	})
+}
+
+// unauditedFields are left out of subscription diffs: the version changes
+// on every update and prices are recorded as they are scheduled.
+var unauditedFields = map[string]bool{"version": true, "price_changes": true}
+
+// diffSubscriptions returns the fields that differ between before and after
+// by their JSON form. A nil subscription has no fields.
+func diffSubscriptions(before, after *model.Subscription) model.AuditChanges {
+	beforeFields, afterFields := jsonFields(before), jsonFields(after)
+	changes := make(model.AuditChanges)
+	for name, value := range beforeFields {
+		if unauditedFields[name] || bytes.Equal(value, afterFields[name]) {
+			continue
+		}
+		changes[name] = model.FieldChange{Before: value, After: afterFields[name]}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok && !unauditedFields[name] {
+			changes[name] = model.FieldChange{After: value}
+		}
+	}
+	return changes
+}
+
+func jsonFields(sub *model.Subscription) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	if sub == nil {
+		return fields
+	}
+	// A subscription always marshals to an object.
+	data, _ := json.Marshal(sub)
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+// fieldChange records a single field going from before to after; nil values
+// are left out.
+func fieldChange(before, after interface{}) model.FieldChange {
+	return model.FieldChange{Before: jsonValue(before), After: jsonValue(after)}
+}
+
+func jsonValue(value interface{}) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/pkg/logger"
+	"time"
+)
+
+var (
+	ErrUnknownCurrency = apperror.Validation("unknown currency", nil)
+	ErrInvalidRates    = apperror.Validation("invalid exchange rates", nil)
+)
+
+type ExchangeRateService interface {
+	SetRates(ctx context.Context, rates []model.ExchangeRate) error
+	GetRates(ctx context.Context) ([]model.ExchangeRate, error)
+	LoadCSV(ctx context.Context, r io.Reader) (int, error)
+	Convert(ctx context.Context, amount model.Money, from, to string) (model.Money, error)
+}
+
+type exchangeRateService struct {
+	repo repository.ExchangeRateRepository
+}
+
+func NewExchangeRateService(repo repository.ExchangeRateRepository) ExchangeRateService {
+	logger.Log.Info("Creating new ExchangeRateService")
+	return &exchangeRateService{repo: repo}
+}
+
+func (s *exchangeRateService) SetRates(ctx context.Context, rates []model.ExchangeRate) error {
+	logger.Log.Infof("Service: setting %d exchange rates", len(rates))
+	now := time.Now()
+	for i := range rates {
+		code, ok := model.NormalizeCurrency(rates[i].Currency)
+		if !ok {
+			return ErrUnknownCurrency.Detailf("%q", rates[i].Currency)
+		}
+		if rates[i].Rate <= 0 || math.IsInf(rates[i].Rate, 0) || math.IsNaN(rates[i].Rate) {
+			return ErrInvalidRates.Detailf("rate for %s must be positive, got %v", code, rates[i].Rate)
+		}
+		rates[i].Currency = code
+		rates[i].UpdatedAt = now
+	}
+	err := s.repo.Upsert(ctx, rates)
+	if err != nil {
+		logger.Log.Errorf("Service: error setting exchange rates: %v", err)
+	}
+	return err
+}
+
+func (s *exchangeRateService) GetRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	logger.Log.Info("Service: getting exchange rates")
+	rates, err := s.repo.GetAll(ctx)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting exchange rates: %v", err)
+		return nil, err
+	}
+	return rates, nil
+}
+
+// LoadCSV reads "currency,rate" lines (an optional header is skipped) and
+// stores them. It returns the number of rates loaded.
+func (s *exchangeRateService) LoadCSV(ctx context.Context, r io.Reader) (int, error) {
+	logger.Log.Info("Service: loading exchange rates from CSV")
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var rates []model.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, ErrInvalidRates.Detailf("csv line %d: %v", line, err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "currency") {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			return 0, ErrInvalidRates.Detailf("csv line %d: invalid rate %q", line, record[1])
+		}
+		rates = append(rates, model.ExchangeRate{Currency: record[0], Rate: rate})
+	}
+
+	if err := s.SetRates(ctx, rates); err != nil {
+		return 0, err
+	}
+	logger.Log.Infof("Service: loaded %d exchange rates from CSV", len(rates))
+	return len(rates), nil
+}
+
+func (s *exchangeRateService) rate(ctx context.Context, currency string) (float64, error) {
+	if currency == model.DefaultCurrency {
+		return 1, nil
+	}
+	rate, err := s.repo.Get(ctx, currency)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, ErrUnknownCurrency.Detailf("%s", currency)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate, nil
+}
+
+func (s *exchangeRateService) Convert(ctx context.Context, amount model.Money, from, to string) (model.Money, error) {
+	if from == to || amount == 0 {
+		return amount, nil
+	}
+	fromRate, err := s.rate(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := s.rate(ctx, to)
+	if err != nil {
+		return 0, err
+	}
+	ratio := new(big.Rat).Quo(new(big.Rat).SetFloat64(fromRate), new(big.Rat).SetFloat64(toRate))
+	converted, err := amount.MulRat(ratio)
+	if err != nil {
+		return 0, err
+	}
+	logger.Log.Debugf("Service: converted %s %s to %s %s", amount, from, converted, to)
+	return converted, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGroupNotFound  = apperror.NotFound("group not found")
+	ErrMemberNotFound = apperror.NotFound("group member not found")
+	ErrInvalidRole    = apperror.Validation("invalid group role", nil)
+	ErrLastOwner      = apperror.Conflict("a group must keep at least one owner")
+)
+
+type GroupService interface {
+	Create(ctx context.Context, name, ownerID string) (*model.Group, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Group, error)
+	GetByUser(ctx context.Context, userID string) ([]model.Group, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Role(ctx context.Context, groupID uuid.UUID, userID string) (model.GroupRole, error)
+	SetMember(ctx context.Context, groupID uuid.UUID, userID string, role model.GroupRole) error
+	RemoveMember(ctx context.Context, groupID uuid.UUID, userID string) error
+}
+
+type groupService struct {
+	repo repository.GroupRepository
+}
+
+func NewGroupService(repo repository.GroupRepository) GroupService {
+	logger.Log.Info("Creating new GroupService")
+	return &groupService{repo: repo}
+}
+
+// Create makes a group with ownerID as its only owner.
+func (s *groupService) Create(ctx context.Context, name, ownerID string) (*model.Group, error) {
+	logger.Log.Infof("Service: creating group '%s' owned by %s", name, ownerID)
+	group := &model.Group{
+		Name:    name,
+		Members: []model.GroupMember{{UserID: ownerID, Role: model.RoleOwner}},
+	}
+	if err := s.repo.Create(ctx, group); err != nil {
+		logger.Log.Errorf("Service: error creating group: %v", err)
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *groupService) GetByID(ctx context.Context, id uuid.UUID) (*model.Group, error) {
+	logger.Log.Infof("Service: getting group %s", id)
+	group, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrGroupNotFound.Detailf("%s", id)
+	}
+	return group, err
+}
+
+func (s *groupService) GetByUser(ctx context.Context, userID string) ([]model.Group, error) {
+	logger.Log.Infof("Service: getting groups of user %s", userID)
+	groups, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting groups: %v", err)
+	}
+	return groups, err
+}
+
+func (s *groupService) Delete(ctx context.Context, id uuid.UUID) error {
+	logger.Log.Infof("Service: deleting group %s", id)
+	err := s.repo.Delete(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrGroupNotFound.Detailf("%s", id)
+	}
+	return err
+}
+
+// Role returns the role of the user in the group, or an empty role when they
+// are not a member.
+func (s *groupService) Role(ctx context.Context, groupID uuid.UUID, userID string) (model.GroupRole, error) {
+	member, err := s.repo.GetMember(ctx, groupID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		logger.Log.Errorf("Service: error getting role of %s in group %s: %v", userID, groupID, err)
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// SetMember adds the user to the group or changes their role. The last owner
+// can't be demoted.
+func (s *groupService) SetMember(ctx context.Context, groupID uuid.UUID, userID string, role model.GroupRole) error {
+	logger.Log.Infof("Service: setting role of %s in group %s to %s", userID, groupID, role)
+	if !role.Valid() {
+		return ErrInvalidRole.Detailf("%q", role)
+	}
+	if role != model.RoleOwner {
+		if err := s.checkNotLastOwner(ctx, groupID, userID); err != nil {
+			return err
+		}
+	}
+	return s.repo.SetMember(ctx, &model.GroupMember{GroupID: groupID, UserID: userID, Role: role})
+}
+
+func (s *groupService) RemoveMember(ctx context.Context, groupID uuid.UUID, userID string) error {
+	logger.Log.Infof("Service: removing %s from group %s", userID, groupID)
+	if err := s.checkNotLastOwner(ctx, groupID, userID); err != nil {
+		return err
+	}
+	err := s.repo.RemoveMember(ctx, groupID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMemberNotFound.Detailf("%s", userID)
+	}
+	return err
+}
+
+func (s *groupService) checkNotLastOwner(ctx context.Context, groupID uuid.UUID, userID string) error {
+	role, err := s.Role(ctx, groupID, userID)
+	if err != nil || role != model.RoleOwner {
+		return err
+	}
+	owners, err := s.repo.CountOwners(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package service_test
+
+import (
+	"os"
+	"testing"
+
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	// The services log through logger.Log, which is nil until initialised.
+	logger.InitLogger()
+	logger.Log.SetLevel(logrus.WarnLevel)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSplit = apperror.Validation("invalid split", nil)
+
+// SetSplit divides the cost of a subscription between users. An empty
+// method without shares removes the split.
+func (s *subscriptionService) SetSplit(ctx context.Context, id uuid.UUID, method model.SplitMethod, shares []model.SplitShare, actor model.Actor) error {
+	logger.Log.Infof("Service: setting %s split of subscription %s", method, id)
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		logger.Log.Errorf("Service: subscription %s not found: %v", id, err)
+		return subscriptionError(id, err)
+	}
+	if err := validateSplit(sub, method, shares); err != nil {
+		logger.Log.Warnf("Service: %v", err)
+		return err
+	}
+	return s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		if err := subs.SetSplit(ctx, id, method, shares); err != nil {
+			return err
+		}
+		return recordChange(ctx, audit, actor, model.AuditSplit, sub, model.AuditChanges{
+			"split_method": fieldChange(sub.SplitMethod, method),
+			"shares":       fieldChange(sub.Shares, shares),
+		})
+	})
+}
+
+func validateSplit(sub *model.Subscription, method model.SplitMethod, shares []model.SplitShare) error {
+	if method == model.SplitNone {
+		if len(shares) > 0 {
+			return ErrInvalidSplit.Detailf("shares need a split method")
+		}
+		return nil
+	}
+	if len(shares) == 0 {
+		return ErrInvalidSplit.Detailf("at least one share is required")
+	}
+
+	seen := make(map[string]bool, len(shares))
+	var percentSum int64
+	var amountSum model.Money
+	for _, share := range shares {
+		if share.UserID == "" || seen[share.UserID] {
+			return ErrInvalidSplit.Detailf("every share needs a distinct user_id")
+		}
+		seen[share.UserID] = true
+
+		switch method {
+		case model.SplitEqual:
+			if share.Percent != nil || share.Amount != nil {
+				return ErrInvalidSplit.Detailf("an equal split takes no percent or amount")
+			}
+		case model.SplitPercentage:
+			if share.Percent == nil || share.Amount != nil {
+				return ErrInvalidSplit.Detailf("a percentage split needs a percent for every share")
+			}
+			if *share.Percent <= 0 || *share.Percent > model.PercentScale {
+				return ErrInvalidSplit.Detailf("percent must be above 0 and at most 100, got %s", *share.Percent)
+			}
+			percentSum += int64(*share.Percent)
+		case model.SplitFixed:
+			if share.Amount == nil || share.Percent != nil {
+				return ErrInvalidSplit.Detailf("a fixed split needs an amount for every share")
+			}
+			var err error
+			if *share.Amount <= 0 {
+				return ErrInvalidSplit.Detailf("amount must be positive, got %s", *share.Amount)
+			}
+			if amountSum, err = amountSum.Add(*share.Amount); err != nil {
+				return ErrInvalidSplit.Detailf("%v", err)
+			}
+		default:
+			return ErrInvalidSplit.Detailf("unknown method %q", method)
+		}
+	}
+
+	if percentSum > model.PercentScale {
+		return ErrInvalidSplit.Detailf("percentages add up to more than 100")
+	}
+	if lowest := lowestPrice(sub); amountSum > lowest {
+		return ErrInvalidSplit.Detailf("amounts add up to more than the lowest price %s", lowest)
+	}
+	return nil
+}
+
+// lowestPrice returns the lowest price sub is charged at, past or scheduled.
+// Fixed shares are taken from every charge, so they must fit into it.
+func lowestPrice(sub *model.Subscription) model.Money {
+	lowest := sub.Price
+	for _, change := range sub.PriceChanges {
+		lowest = min(lowest, change.Price)
+	}
+	return lowest
+}
+
+// checkSplitPrice rejects a new price of sub that is lower than its fixed
+// shares add up to, which would leave the payer a negative part.
+func checkSplitPrice(sub *model.Subscription, price model.Money) error {
+	if sub.SplitMethod != model.SplitFixed {
+		return nil
+	}
+	var shared model.Money
+	for _, share := range sub.Shares {
+		if share.Amount == nil {
+			continue
+		}
+		var err error
+		if shared, err = shared.Add(*share.Amount); err != nil {
+			return err
+		}
+	}
+	if price < shared {
+		return ErrInvalidPriceChange.Detailf("price %s is below the fixed split amounts of %s", price, shared)
+	}
+	return nil
+}
+
+// splitCharges divides the charges of a subscription between the users with
+// shares. The payer pays the rest, including rounding differences, so the
+// parts always add up to the charges.
+func splitCharges(charges model.SubscriptionCharges, shares []model.SplitShare) (map[string]model.Money, error) {
+	parts := make(map[string]model.Money, len(shares)+1)
+	var shared model.Money
+	for _, share := range shares {
+		if share.UserID == charges.UserID {
+			continue
+		}
+
+		var part model.Money
+		var err error
+		switch {
+		case charges.SplitMethod == model.SplitEqual:
+			part, err = charges.Total.MulRat(big.NewRat(1, int64(len(shares))))
+		case charges.SplitMethod == model.SplitPercentage && share.Percent != nil:
+			part, err = charges.Total.MulRat(share.Percent.Rat())
+		case charges.SplitMethod == model.SplitFixed && share.Amount != nil:
+			part, err = share.Amount.Mul(charges.Charges)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if shared, err = shared.Add(part); err != nil {
+			return nil, err
+		}
+		parts[share.UserID] = part
+	}
+
+	payer, err := charges.Total.Add(-shared)
+	if err != nil {
+		return nil, err
+	}
+	parts[charges.UserID] = payer
+	return parts, nil
+}
+
+// chargesWithShares returns the charges for filter with the split shares of
+// every charged subscription.
+func (s *subscriptionService) chargesWithShares(ctx context.Context, filter model.SubscriptionFilter, from, to *time.Time) ([]model.SubscriptionCharges, map[uuid.UUID][]model.SplitShare, error) {
+	charges, err := s.repo.CalcCharges(ctx, filter, from, to)
+	if err != nil {
+		logger.Log.Errorf("Service: error calculating charges: %v", err)
+		return nil, nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(charges))
+	for _, c := range charges {
+		if c.SplitMethod != model.SplitNone {
+			ids = append(ids, c.SubscriptionID)
+		}
+	}
+	shares, err := s.repo.GetShares(ctx, ids)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting split shares: %v", err)
+		return nil, nil, err
+	}
+
+	bySubscription := make(map[uuid.UUID][]model.SplitShare, len(ids))
+	for _, share := range shares {
+		bySubscription[share.SubscriptionID] = append(bySubscription[share.SubscriptionID], share)
+	}
+	return charges, bySubscription, nil
+}
+
+// GetShareTotal is GetTotal counting, instead of the full price, only the
+// part of every subscription that filter.ParticipantID pays.
+func (s *subscriptionService) GetShareTotal(ctx context.Context, filter model.SubscriptionFilter, currency string, from, to *time.Time) (*model.Total, error) {
+	logger.Log.Infof("Service: calculating share total in %s for %+v, from %v to %v", currency, filter, from, to)
+	charges, shares, err := s.chargesWithShares(ctx, filter, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := make(map[string]model.Money)
+	for _, c := range charges {
+		parts, err := splitCharges(c, shares[c.SubscriptionID])
+		if err != nil {
+			logger.Log.Errorf("Service: error splitting charges of subscription %s: %v", c.SubscriptionID, err)
+			return nil, err
+		}
+		if breakdown[c.Currency], err = breakdown[c.Currency].Add(parts[filter.ParticipantID]); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.convertTotal(ctx, breakdown, currency)
+}
+
+// GetSettlement nets what userID and the people they share subscriptions
+// with owe each other for the charges between from and to.
+func (s *subscriptionService) GetSettlement(ctx context.Context, userID string, currency string, from, to *time.Time) (*model.Settlement, error) {
+	logger.Log.Infof("Service: calculating settlement in %s for user %s, from %v to %v", currency, userID, from, to)
+	charges, shares, err := s.chargesWithShares(ctx, model.SubscriptionFilter{ParticipantID: userID}, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// balances[{a, b}] with a < b is what a owes b; negative when b owes a.
+	balances := make(map[[2]string]model.Money)
+	for _, c := range charges {
+		if c.SplitMethod == model.SplitNone {
+			continue
+		}
+		parts, err := splitCharges(c, shares[c.SubscriptionID])
+		if err != nil {
+			logger.Log.Errorf("Service: error splitting charges of subscription %s: %v", c.SubscriptionID, err)
+			return nil, err
+		}
+		for debtor, part := range parts {
+			if debtor == c.UserID || part == 0 || (debtor != userID && c.UserID != userID) {
+				continue
+			}
+			converted, err := s.rates.Convert(ctx, part, c.Currency, currency)
+			if err != nil {
+				logger.Log.Errorf("Service: error converting %s %s to %s: %v", part, c.Currency, currency, err)
+				return nil, err
+			}
+			pair := [2]string{debtor, c.UserID}
+			if debtor > c.UserID {
+				pair, converted = [2]string{c.UserID, debtor}, -converted
+			}
+			if balances[pair], err = balances[pair].Add(converted); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	settlement := &model.Settlement{Currency: currency, Debts: []model.Debt{}}
+	for pair, amount := range balances {
+		switch {
+		case amount > 0:
+			settlement.Debts = append(settlement.Debts, model.Debt{From: pair[0], To: pair[1], Amount: amount})
+		case amount < 0:
+			settlement.Debts = append(settlement.Debts, model.Debt{From: pair[1], To: pair[0], Amount: -amount})
+		}
+	}
+	sort.Slice(settlement.Debts, func(i, j int) bool {
+		a, b := settlement.Debts[i], settlement.Debts[j]
+		return a.From < b.From || (a.From == b.From && a.To < b.To)
+	})
+
+	logger.Log.Infof("Service: settlement has %d debts", len(settlement.Debts))
+	return settlement, nil
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"subscription-aggregator/internal/apperror"
 	"subscription-aggregator/internal/model"
 	"subscription-aggregator/internal/repository"
 	"subscription-aggregator/pkg/logger"
@@ -10,26 +13,57 @@ import (
 )
 
 type SubscriptionService interface {
-	Create(sub *model.Subscription) error
-	GetByID(id uuid.UUID) (*model.Subscription, error)
-	Update(sub *model.Subscription) error
-	Delete(id uuid.UUID) error
-	GetList(filter model.Subscription, offset, limit int) ([]model.Subscription, error)
-	GetTotal(userID string, serviceName string, from, to *time.Time) (uint, error)
+	Create(ctx context.Context, sub *model.Subscription, actor model.Actor) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	Update(ctx context.Context, sub *model.Subscription, actor model.Actor) error
+	Delete(ctx context.Context, id uuid.UUID, version uint, actor model.Actor) error
+	DeleteByUser(ctx context.Context, userID string, actor model.Actor) (int64, error)
+	GetDeleted(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	Restore(ctx context.Context, id uuid.UUID, actor model.Actor) (*model.Subscription, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	GetList(ctx context.Context, filter model.SubscriptionFilter, offset, limit int) ([]model.Subscription, int64, error)
+	GetPage(ctx context.Context, filter model.SubscriptionFilter, cursor *model.Cursor, limit int) (*model.CursorPage, error)
+	GetTotal(ctx context.Context, filter model.SubscriptionFilter, currency string, from, to *time.Time) (*model.Total, error)
+	GetMonthly(ctx context.Context, filter model.SubscriptionFilter, currency string, from, to time.Time) (*model.SpendingSeries, error)
+	SchedulePrice(ctx context.Context, id uuid.UUID, version uint, price model.Money, from time.Time, backdate bool, actor model.Actor) (*model.PriceChange, error)
+	GetPriceHistory(ctx context.Context, id uuid.UUID) ([]model.PriceChange, error)
+	SetSplit(ctx context.Context, id uuid.UUID, method model.SplitMethod, shares []model.SplitShare, actor model.Actor) error
+	GetShareTotal(ctx context.Context, filter model.SubscriptionFilter, currency string, from, to *time.Time) (*model.Total, error)
+	GetSettlement(ctx context.Context, userID string, currency string, from, to *time.Time) (*model.Settlement, error)
 }
 
+var (
+	ErrSubscriptionNotFound = apperror.NotFound("subscription not found")
+	ErrInvalidPriceChange   = apperror.Validation("invalid price change", nil)
+	ErrBackdatedPriceChange = apperror.Validation("invalid price change", map[string]string{
+		"from": "must not be before the current month unless an admin sets backdate",
+	})
+)
+
+// subscriptionService records every change in the audit log within the
+// transaction that makes it.
 type subscriptionService struct {
-	repo repository.SubscriptionRepository
+	repo  repository.SubscriptionRepository
+	tx    repository.Transactor
+	rates ExchangeRateService
 }
 
-func NewSubscriptionService(repo repository.SubscriptionRepository) SubscriptionService {
+func NewSubscriptionService(repo repository.SubscriptionRepository, tx repository.Transactor, rates ExchangeRateService) SubscriptionService {
 	logger.Log.Info("Creating new SubscriptionService")
-	return &subscriptionService{repo: repo}
+	return &subscriptionService{repo: repo, tx: tx, rates: rates}
 }
 
-func (s *subscriptionService) Create(sub *model.Subscription) error {
+func (s *subscriptionService) Create(ctx context.Context, sub *model.Subscription, actor model.Actor) error {
 	logger.Log.Infof("Service: creating subscription for user %s, service %s", sub.UserID, sub.ServiceName)
-	err := s.repo.Create(sub)
+	if err := s.checkCurrency(ctx, sub.Currency); err != nil {
+		return err
+	}
+	err := s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		if err := subs.Create(ctx, sub); err != nil {
+			return err
+		}
+		return recordChange(ctx, audit, actor, model.AuditCreate, sub, diffSubscriptions(nil, sub))
+	})
 	if err != nil {
 		logger.Log.Errorf("Service: error creating subscription: %v", err)
 	} else {
@@ -38,31 +72,135 @@ func (s *subscriptionService) Create(sub *model.Subscription) error {
 	return err
 }
 
-func (s *subscriptionService) GetByID(id uuid.UUID) (*model.Subscription, error) {
+func (s *subscriptionService) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
 	logger.Log.Infof("Service: getting subscription by ID %s", id)
-	sub, err := s.repo.GetByID(id)
+	sub, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		logger.Log.Errorf("Service: subscription with ID %s not found: %v", id, err)
-		return nil, err
+		return nil, subscriptionError(id, err)
 	}
 	logger.Log.Infof("Service: subscription with ID %s retrieved", id)
 	return sub, nil
 }
 
-func (s *subscriptionService) Update(sub *model.Subscription) error {
+func (s *subscriptionService) Update(ctx context.Context, sub *model.Subscription, actor model.Actor) error {
 	logger.Log.Infof("Service: updating subscription with ID %s", sub.ID)
-	err := s.repo.Update(sub)
+	if err := s.checkCurrency(ctx, sub.Currency); err != nil {
+		return err
+	}
+	old, err := s.repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		logger.Log.Errorf("Service: subscription with ID %s not found: %v", sub.ID, err)
+		return subscriptionError(sub.ID, err)
+	}
+	if sub.Price != old.Price {
+		if err := checkSplitPrice(old, sub.Price); err != nil {
+			logger.Log.Warnf("Service: %v", err)
+			return err
+		}
+	}
+
+	err = s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		if err := subs.Update(ctx, sub); err != nil {
+			return err
+		}
+		if sub.Price != old.Price {
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			logger.Log.Infof("Service: price of subscription %s changed from %s to %s", sub.ID, old.Price, sub.Price)
+			if err := subs.AddPriceChange(ctx, &model.PriceChange{SubscriptionID: sub.ID, Price: sub.Price, EffectiveFrom: today}); err != nil {
+				return err
+			}
+		}
+		// Shares are only changed through SetSplit.
+		after := *sub
+		after.Shares = old.Shares
+		return recordChange(ctx, audit, actor, model.AuditUpdate, sub, diffSubscriptions(old, &after))
+	})
 	if err != nil {
 		logger.Log.Errorf("Service: error updating subscription ID %s: %v", sub.ID, err)
-	} else {
-		logger.Log.Infof("Service: subscription ID %s updated successfully", sub.ID)
+		return err
 	}
-	return err
+
+	logger.Log.Infof("Service: subscription ID %s updated successfully", sub.ID)
+	return nil
 }
 
-func (s *subscriptionService) Delete(id uuid.UUID) error {
+// SchedulePrice makes price effective for the subscription from the given
+// date on. Future dates schedule a price change; a change on the same date
+// replaces the earlier one. Totals of past months have already been reported,
+// so dates before the current month are rejected unless backdate is set.
+// The subscription must be at version and moves to the next one.
+func (s *subscriptionService) SchedulePrice(ctx context.Context, id uuid.UUID, version uint, price model.Money, from time.Time, backdate bool, actor model.Actor) (*model.PriceChange, error) {
+	logger.Log.Infof("Service: scheduling price %s for subscription %s at version %d from %s (backdate: %t)", price, id, version, from.Format("2006-01-02"), backdate)
+	if price < 0 {
+		return nil, ErrInvalidPriceChange.Detailf("price must not be negative")
+	}
+	now := time.Now().UTC()
+	if !backdate && from.Before(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+		logger.Log.Warnf("Service: price change of subscription %s from %s is before the current month", id, from.Format("2006-01-02"))
+		return nil, ErrBackdatedPriceChange
+	}
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		logger.Log.Errorf("Service: subscription with ID %s not found: %v", id, err)
+		return nil, subscriptionError(id, err)
+	}
+	if from.Before(sub.StartDate) {
+		return nil, ErrInvalidPriceChange.Detailf("price change before the subscription start date")
+	}
+	if err := checkSplitPrice(sub, price); err != nil {
+		logger.Log.Warnf("Service: %v", err)
+		return nil, err
+	}
+
+	change := &model.PriceChange{SubscriptionID: id, Price: price, EffectiveFrom: from}
+	err = s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		if err := subs.BumpVersion(ctx, id, version); err != nil {
+			return err
+		}
+		if err := subs.AddPriceChange(ctx, change); err != nil {
+			return err
+		}
+		return recordChange(ctx, audit, actor, model.AuditPrice, sub, model.AuditChanges{
+			"price":          fieldChange(sub.PriceAt(from), price),
+			"effective_from": fieldChange(nil, from),
+		})
+	})
+	if err != nil {
+		logger.Log.Errorf("Service: error scheduling price for subscription %s: %v", id, err)
+		return nil, err
+	}
+	logger.Log.Infof("Service: price change %s scheduled", change.ID)
+	return change, nil
+}
+
+func (s *subscriptionService) GetPriceHistory(ctx context.Context, id uuid.UUID) ([]model.PriceChange, error) {
+	logger.Log.Infof("Service: getting price history of subscription %s", id)
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		logger.Log.Errorf("Service: subscription with ID %s not found: %v", id, err)
+		return nil, subscriptionError(id, err)
+	}
+	changes, err := s.repo.GetPriceChanges(ctx, id)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting price history: %v", err)
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (s *subscriptionService) Delete(ctx context.Context, id uuid.UUID, version uint, actor model.Actor) error {
 	logger.Log.Infof("Service: deleting subscription with ID %s", id)
-	err := s.repo.Delete(id)
+	old, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		logger.Log.Errorf("Service: subscription with ID %s not found: %v", id, err)
+		return subscriptionError(id, err)
+	}
+	err = s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		if err := subs.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		return recordChange(ctx, audit, actor, model.AuditDelete, old, diffSubscriptions(old, nil))
+	})
 	if err != nil {
 		logger.Log.Errorf("Service: error deleting subscription ID %s: %v", id, err)
 	} else {
@@ -71,24 +209,212 @@ func (s *subscriptionService) Delete(id uuid.UUID) error {
 	return err
 }
 
-func (s *subscriptionService) GetList(filter model.Subscription, offset, limit int) ([]model.Subscription, error) {
+// DeleteByUser marks every subscription of the user deleted and returns how
+// many there were.
+func (s *subscriptionService) DeleteByUser(ctx context.Context, userID string, actor model.Actor) (int64, error) {
+	logger.Log.Infof("Service: deleting subscriptions of user %s", userID)
+	owned, _, err := s.repo.GetList(ctx, model.SubscriptionFilter{UserID: userID}, 0, 0)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting subscriptions of user %s: %v", userID, err)
+		return 0, err
+	}
+	err = s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		for i := range owned {
+			old := &owned[i]
+			if err := subs.Delete(ctx, old.ID, old.Version); err != nil {
+				return err
+			}
+			if err := recordChange(ctx, audit, actor, model.AuditDelete, old, diffSubscriptions(old, nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Service: error deleting subscriptions of user %s: %v", userID, err)
+		return 0, err
+	}
+	logger.Log.Infof("Service: %d subscriptions of user %s deleted", len(owned), userID)
+	return int64(len(owned)), nil
+}
+
+func (s *subscriptionService) GetDeleted(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	logger.Log.Infof("Service: getting deleted subscription by ID %s", id)
+	sub, err := s.repo.GetDeletedByID(ctx, id)
+	if err != nil {
+		logger.Log.Errorf("Service: deleted subscription with ID %s not found: %v", id, err)
+		return nil, subscriptionError(id, err)
+	}
+	return sub, nil
+}
+
+// Restore undoes the deletion of a subscription and returns it.
+func (s *subscriptionService) Restore(ctx context.Context, id uuid.UUID, actor model.Actor) (*model.Subscription, error) {
+	logger.Log.Infof("Service: restoring subscription with ID %s", id)
+	old, err := s.repo.GetDeletedByID(ctx, id)
+	if err != nil {
+		logger.Log.Errorf("Service: deleted subscription with ID %s not found: %v", id, err)
+		return nil, subscriptionError(id, err)
+	}
+
+	var sub *model.Subscription
+	err = s.tx.Transaction(ctx, func(subs repository.SubscriptionRepository, audit repository.AuditRepository) error {
+		if err := subs.Restore(ctx, id); err != nil {
+			return err
+		}
+		if sub, err = subs.GetByID(ctx, id); err != nil {
+			return err
+		}
+		return recordChange(ctx, audit, actor, model.AuditRestore, sub, diffSubscriptions(old, sub))
+	})
+	if err != nil {
+		logger.Log.Errorf("Service: error restoring subscription ID %s: %v", id, err)
+		return nil, subscriptionError(id, err)
+	}
+	logger.Log.Infof("Service: subscription ID %s restored successfully", id)
+	return sub, nil
+}
+
+// PurgeDeleted permanently removes the subscriptions deleted before the given
+// time. Their audit log stays.
+func (s *subscriptionService) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	logger.Log.Infof("Service: purging subscriptions deleted before %v", before)
+	count, err := s.repo.PurgeDeleted(ctx, before)
+	if err != nil {
+		logger.Log.Errorf("Service: error purging deleted subscriptions: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *subscriptionService) GetList(ctx context.Context, filter model.SubscriptionFilter, offset, limit int) ([]model.Subscription, int64, error) {
 	logger.Log.Infof("Service: getting subscription list for user %s with offset %d and limit %d", filter.UserID, offset, limit)
-	subs, err := s.repo.GetList(filter, offset, limit)
+	subs, total, err := s.repo.GetList(ctx, filter, offset, limit)
 	if err != nil {
 		logger.Log.Errorf("Service: error getting subscription list: %v", err)
+		return nil, 0, err
+	}
+	logger.Log.Infof("Service: retrieved %d of %d subscriptions", len(subs), total)
+	return subs, total, nil
+}
+
+// GetPage returns the subscriptions next to cursor (the first ones without a
+// cursor) in (start_date, id) order, with the cursors of the neighbouring
+// pages.
+func (s *subscriptionService) GetPage(ctx context.Context, filter model.SubscriptionFilter, cursor *model.Cursor, limit int) (*model.CursorPage, error) {
+	logger.Log.Infof("Service: getting subscription page for user %s after cursor %+v with limit %d", filter.UserID, cursor, limit)
+	subs, hasMore, err := s.repo.GetListAfter(ctx, filter, cursor, limit)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting subscription page: %v", err)
 		return nil, err
 	}
+
+	page := &model.CursorPage{Items: subs}
+	if len(subs) == 0 {
+		return page, nil
+	}
+	// Going forward there is a next page when the repository found more rows
+	// and a previous one unless this is the first page; going backward it is
+	// the other way round.
+	first, last := subs[0], subs[len(subs)-1]
+	hasNext, hasPrev := hasMore, cursor != nil
+	if cursor != nil && cursor.Backward {
+		hasNext, hasPrev = true, hasMore
+	}
+	if hasNext {
+		page.Next = &model.Cursor{StartDate: last.StartDate, ID: last.ID, Desc: filter.SortDesc}
+	}
+	if hasPrev {
+		page.Prev = &model.Cursor{StartDate: first.StartDate, ID: first.ID, Desc: filter.SortDesc, Backward: true}
+	}
+
 	logger.Log.Infof("Service: retrieved %d subscriptions", len(subs))
-	return subs, nil
+	return page, nil
 }
 
-func (s *subscriptionService) GetTotal(userID string, serviceName string, from, to *time.Time) (uint, error) {
-	logger.Log.Infof("Service: calculating total for user %s, service %s, from %v to %v", userID, serviceName, from, to)
-	total, err := s.repo.CalcTotal(userID, serviceName, from, to)
+func (s *subscriptionService) GetTotal(ctx context.Context, filter model.SubscriptionFilter, currency string, from, to *time.Time) (*model.Total, error) {
+	logger.Log.Infof("Service: calculating total in %s for %+v, from %v to %v", currency, filter, from, to)
+	breakdown, err := s.repo.CalcTotal(ctx, filter, from, to)
 	if err != nil {
 		logger.Log.Errorf("Service: error calculating total: %v", err)
-		return 0, err
+		return nil, err
 	}
-	logger.Log.Infof("Service: total calculated: %d", total)
+
+	return s.convertTotal(ctx, breakdown, currency)
+}
+
+// convertTotal adds up per-currency amounts in currency.
+func (s *subscriptionService) convertTotal(ctx context.Context, breakdown map[string]model.Money, currency string) (*model.Total, error) {
+	total := &model.Total{Currency: currency, Breakdown: breakdown}
+	for code, amount := range breakdown {
+		converted, err := s.rates.Convert(ctx, amount, code, currency)
+		if err != nil {
+			logger.Log.Errorf("Service: error converting %s %s to %s: %v", amount, code, currency, err)
+			return nil, err
+		}
+		if total.Sum, err = total.Sum.Add(converted); err != nil {
+			logger.Log.Errorf("Service: total overflow: %v", err)
+			return nil, err
+		}
+	}
+
+	logger.Log.Infof("Service: total calculated: %s %s", total.Sum, currency)
 	return total, nil
 }
+
+func (s *subscriptionService) GetMonthly(ctx context.Context, filter model.SubscriptionFilter, currency string, from, to time.Time) (*model.SpendingSeries, error) {
+	logger.Log.Infof("Service: calculating monthly spending in %s for %+v, from %v to %v", currency, filter, from, to)
+	rows, err := s.repo.CalcMonthly(ctx, filter, from, to)
+	if err != nil {
+		logger.Log.Errorf("Service: error calculating monthly spending: %v", err)
+		return nil, err
+	}
+
+	series := &model.SpendingSeries{Currency: currency}
+	index := make(map[string]int)
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(to); month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		index[key] = len(series.Months)
+		series.Months = append(series.Months, model.MonthlySpending{Month: key, Services: map[string]model.Money{}})
+	}
+
+	for _, row := range rows {
+		i, ok := index[row.Month]
+		if !ok {
+			logger.Log.Warnf("Service: unexpected month %s in monthly spending", row.Month)
+			continue
+		}
+		converted, err := s.rates.Convert(ctx, row.Total, row.Currency, currency)
+		if err != nil {
+			logger.Log.Errorf("Service: error converting %s %s to %s: %v", row.Total, row.Currency, currency, err)
+			return nil, err
+		}
+		month := &series.Months[i]
+		if month.Services[row.ServiceName], err = month.Services[row.ServiceName].Add(converted); err != nil {
+			return nil, err
+		}
+		if month.Total, err = month.Total.Add(converted); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Log.Infof("Service: monthly spending calculated for %d months", len(series.Months))
+	return series, nil
+}
+
+// subscriptionError reports a missing subscription as ErrSubscriptionNotFound.
+func subscriptionError(id uuid.UUID, err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSubscriptionNotFound.Detailf("%s", id)
+	}
+	return err
+}
+
+// checkCurrency makes sure totals can be converted from currency later on.
+func (s *subscriptionService) checkCurrency(ctx context.Context, currency string) error {
+	_, err := s.rates.Convert(ctx, 1, currency, model.DefaultCurrency)
+	if err != nil {
+		logger.Log.Warnf("Service: currency %s is not convertible: %v", currency, err)
+	}
+	return err
+}
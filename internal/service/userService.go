@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound = apperror.NotFound("user not found")
+	ErrUserExists   = apperror.Conflict("user already exists")
+)
+
+type UserService interface {
+	Create(ctx context.Context, user *model.User) error
+	GetByID(ctx context.Context, id string) (*model.User, error)
+	GetList(ctx context.Context, offset, limit int) ([]model.User, int64, error)
+	Delete(ctx context.Context, id string, actor model.Actor) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+type userService struct {
+	repo repository.UserRepository
+	subs SubscriptionService
+}
+
+func NewUserService(repo repository.UserRepository, subs SubscriptionService) UserService {
+	logger.Log.Info("Creating new UserService")
+	return &userService{repo: repo, subs: subs}
+}
+
+// Create stores a new user. Users without an ID get a random UUID.
+func (s *userService) Create(ctx context.Context, user *model.User) error {
+	logger.Log.Infof("Service: creating user %+v", user)
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	} else if _, err := s.GetByID(ctx, user.ID); err == nil {
+		return ErrUserExists.Detailf("%s", user.ID)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	err := s.repo.Create(ctx, user)
+	if errors.Is(err, repository.ErrConflict) {
+		return ErrUserExists.Detailf("%s", user.ID)
+	}
+	if err != nil {
+		logger.Log.Errorf("Service: error creating user: %v", err)
+	}
+	return err
+}
+
+func (s *userService) GetByID(ctx context.Context, id string) (*model.User, error) {
+	logger.Log.Infof("Service: getting user by ID %s", id)
+	user, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound.Detailf("%s", id)
+	}
+	if err != nil {
+		logger.Log.Errorf("Service: error getting user %s: %v", id, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) GetList(ctx context.Context, offset, limit int) ([]model.User, int64, error) {
+	logger.Log.Infof("Service: getting users list, offset %d, limit %d", offset, limit)
+	users, total, err := s.repo.GetList(ctx, offset, limit)
+	if err != nil {
+		logger.Log.Errorf("Service: error getting users list: %v", err)
+	}
+	return users, total, err
+}
+
+// Delete marks the user and their subscriptions deleted, recording the
+// subscriptions in the audit log as deleted by actor. The retention purge
+// removes the user with the last of them.
+func (s *userService) Delete(ctx context.Context, id string, actor model.Actor) error {
+	logger.Log.Infof("Service: deleting user %s", id)
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return err
+	}
+	if _, err := s.subs.DeleteByUser(ctx, id, actor); err != nil {
+		logger.Log.Errorf("Service: error deleting subscriptions of user %s: %v", id, err)
+		return err
+	}
+	err := s.repo.Delete(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound.Detailf("%s", id)
+	}
+	if err != nil {
+		logger.Log.Errorf("Service: error deleting user %s: %v", id, err)
+	}
+	return err
+}
+
+// PurgeDeleted permanently removes the users deleted before the given time
+// whose subscriptions are all purged.
+func (s *userService) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	logger.Log.Infof("Service: purging users deleted before %v", before)
+	count, err := s.repo.PurgeDeleted(ctx, before)
+	if err != nil {
+		logger.Log.Errorf("Service: error purging deleted users: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
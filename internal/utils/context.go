@@ -1,7 +1,10 @@
 package utils
 
 import (
-	"net/http"
+	"strconv"
+	"strings"
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/model"
 	"subscription-aggregator/pkg/logger"
 	"time"
 
@@ -9,20 +12,19 @@ import (
 	"github.com/google/uuid"
 )
 
-func CheckID(context *gin.Context) (uuid.UUID, bool) {
+func CheckID(context *gin.Context) (uuid.UUID, error) {
 	idParam := context.Param("id")
 	logger.Log.Infof("Parsing UUID from param: %s", idParam)
 	id, err := uuid.Parse(idParam)
 	if err != nil {
 		logger.Log.Errorf("Invalid UUID format: %s, error: %v", idParam, err)
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
-		return uuid.Nil, false
+		return uuid.Nil, apperror.Validation("invalid UUID", nil)
 	}
 	logger.Log.Infof("Successfully parsed UUID: %s", id)
-	return id, true
+	return id, nil
 }
 
-func GetDate(context *gin.Context) (time.Time, *time.Time) {
+func GetDate(context *gin.Context) (time.Time, *time.Time, error) {
 	fromStr := context.Query("from")
 	toStr := context.Query("to")
 	logger.Log.Infof("Parsing dates from query params: from='%s', to='%s'", fromStr, toStr)
@@ -34,8 +36,7 @@ func GetDate(context *gin.Context) (time.Time, *time.Time) {
 		from, err = time.Parse("2006-01-02", fromStr)
 		if err != nil {
 			logger.Log.Errorf("Invalid 'from' date format: %s, error: %v", fromStr, err)
-			context.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' date"})
-			return time.Time{}, nil
+			return time.Time{}, nil, apperror.Validation("invalid 'from' date", nil)
 		}
 		logger.Log.Infof("Parsed 'from' date: %s", from.Format("2006-01-02"))
 	}
@@ -44,12 +45,149 @@ func GetDate(context *gin.Context) (time.Time, *time.Time) {
 		t, err := time.Parse("2006-01-02", toStr)
 		if err != nil {
 			logger.Log.Errorf("Invalid 'to' date format: %s, error: %v", toStr, err)
-			context.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' date"})
-			return time.Time{}, nil
+			return time.Time{}, nil, apperror.Validation("invalid 'to' date", nil)
 		}
 		to = &t
 		logger.Log.Infof("Parsed 'to' date: %s", to.Format("2006-01-02"))
 	}
 
-	return from, to
+	return from, to, nil
+}
+
+func GetBillingPeriod(context *gin.Context) (model.BillingPeriod, uint, error) {
+	period := model.BillingPeriod(context.Query("billing_period"))
+	daysStr := context.Query("billing_period_days")
+	logger.Log.Infof("Parsing billing period from query params: billing_period='%s', billing_period_days='%s'", period, daysStr)
+
+	if period == "" {
+		return "", 0, nil
+	}
+
+	var days uint
+	if daysStr != "" {
+		parsed, err := strconv.ParseUint(daysStr, 10, 32)
+		if err != nil {
+			logger.Log.Errorf("Invalid 'billing_period_days': %s, error: %v", daysStr, err)
+			return "", 0, apperror.Validation("invalid billing_period_days", nil)
+		}
+		days = uint(parsed)
+	}
+
+	if !period.Valid(days) {
+		logger.Log.Errorf("Invalid billing period: %s (%d days)", period, days)
+		return "", 0, apperror.Validation("invalid billing_period", nil)
+	}
+	if period != model.BillingCustom {
+		days = 0
+	}
+
+	return period, days, nil
+}
+
+func GetCurrency(context *gin.Context, fallback string) (string, error) {
+	currencyStr := context.Query("currency")
+	logger.Log.Infof("Parsing currency from query param: currency='%s'", currencyStr)
+
+	if currencyStr == "" {
+		return fallback, nil
+	}
+
+	currency, ok := model.NormalizeCurrency(currencyStr)
+	if !ok {
+		logger.Log.Errorf("Invalid currency code: %s", currencyStr)
+		return "", apperror.Validation("invalid currency", nil)
+	}
+	return currency, nil
+}
+
+// GetBool parses an optional boolean query parameter.
+func GetBool(context *gin.Context, name string) (bool, error) {
+	value := context.Query(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Log.Warnf("Invalid %s query param: %v", name, err)
+		return false, apperror.Validation("invalid "+name, nil)
+	}
+	return parsed, nil
+}
+
+// ParseDate accepts both plain dates (yyyy-mm-dd) and RFC 3339 timestamps, so
+// that clients can send back the dates they received.
+func ParseDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func SetETag(context *gin.Context, version uint) {
+	context.Header("ETag", `"`+strconv.FormatUint(uint64(version), 10)+`"`)
+}
+
+// CheckIfMatch compares the If-Match header with the current version of a
+// resource. It fails with a precondition-required error when the header is
+// missing, a validation error when it is not "*" or a list of entity tags,
+// and a precondition-failed one when it names another version. "*" matches
+// any version. If-Match uses the strong comparison, so weak tags (W/"1")
+// never match.
+func CheckIfMatch(context *gin.Context, current uint) (uint, error) {
+	header := strings.TrimSpace(context.GetHeader("If-Match"))
+	logger.Log.Infof("Checking If-Match header '%s' against version %d", header, current)
+
+	if header == "" {
+		logger.Log.Warn("Missing If-Match header")
+		return 0, apperror.New(apperror.KindPreconditionRequired, "If-Match header is required")
+	}
+	if header == "*" {
+		return current, nil
+	}
+
+	matched := false
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		opaque, weak, ok := parseEntityTag(tag)
+		if !ok {
+			logger.Log.Warnf("Malformed If-Match header '%s'", header)
+			return 0, apperror.Validation("invalid If-Match header", nil)
+		}
+		if weak {
+			continue
+		}
+		version, err := strconv.ParseUint(opaque, 10, 32)
+		if err == nil && uint(version) == current {
+			matched = true
+		}
+	}
+	if matched {
+		return current, nil
+	}
+
+	logger.Log.Warnf("If-Match '%s' does not match version %d", header, current)
+	SetETag(context, current)
+	return 0, apperror.New(apperror.KindPreconditionFailed, "the subscription has been modified")
+}
+
+// parseEntityTag splits an entity tag, such as "1" or W/"1", into its opaque
+// part and whether it is weak. ok is false unless the tag is quoted and
+// contains only the characters RFC 9110 allows.
+func parseEntityTag(tag string) (opaque string, weak bool, ok bool) {
+	if rest, found := strings.CutPrefix(tag, "W/"); found {
+		tag, weak = rest, true
+	}
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return "", false, false
+	}
+	opaque = tag[1 : len(tag)-1]
+	for i := 0; i < len(opaque); i++ {
+		if c := opaque[i]; c == '"' || c < 0x21 || c == 0x7f {
+			return "", false, false
+		}
+	}
+	return opaque, weak, true
 }
@@ -0,0 +1,70 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"subscription-aggregator/internal/apperror"
+	"subscription-aggregator/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	const current = 3
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int // zero when the header matches
+		wantETag   bool
+	}{
+		{name: "any version", header: "*"},
+		{name: "current version", header: `"3"`},
+		{name: "current version in a list", header: `"1", "3"`},
+		{name: "list without spaces", header: `"2","3"`},
+		{name: "empty list elements", header: `, "3",`},
+		{name: "missing", header: "", wantStatus: http.StatusPreconditionRequired},
+		{name: "blank", header: "  ", wantStatus: http.StatusPreconditionRequired},
+		{name: "other version", header: `"2"`, wantStatus: http.StatusPreconditionFailed, wantETag: true},
+		{name: "list of other versions", header: `"1", "2"`, wantStatus: http.StatusPreconditionFailed, wantETag: true},
+		{name: "weak tag of the current version", header: `W/"3"`, wantStatus: http.StatusPreconditionFailed, wantETag: true},
+		{name: "weak and strong tags", header: `W/"3", "3"`},
+		{name: "tag that is not a version", header: `"abc"`, wantStatus: http.StatusPreconditionFailed, wantETag: true},
+		{name: "unquoted tag", header: "3", wantStatus: http.StatusBadRequest},
+		{name: "unquoted tag in a list", header: `"1", 3`, wantStatus: http.StatusBadRequest},
+		{name: "unquoted weak tag", header: "W/3", wantStatus: http.StatusBadRequest},
+		{name: "half-quoted tag", header: `"3`, wantStatus: http.StatusBadRequest},
+		{name: "lowercase weak prefix", header: `w/"3"`, wantStatus: http.StatusBadRequest},
+		{name: "space inside a tag", header: `"3 4"`, wantStatus: http.StatusBadRequest},
+		{name: "star in a list", header: `*, "3"`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+			if test.header != "" {
+				context.Request.Header.Set("If-Match", test.header)
+			}
+
+			version, err := utils.CheckIfMatch(context, current)
+			if test.wantStatus == 0 {
+				if err != nil || version != current {
+					t.Fatalf("CheckIfMatch = %d, %v, want %d, nil", version, err, current)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("CheckIfMatch = %d, nil, want status %d", version, test.wantStatus)
+			}
+			if status := apperror.KindOf(err).Status(); status != test.wantStatus {
+				t.Fatalf("CheckIfMatch error = %v with status %d, want %d", err, status, test.wantStatus)
+			}
+			if got := recorder.Header().Get("ETag"); test.wantETag && got != `"3"` {
+				t.Errorf("ETag = %q, want the current version", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package utils_test
+
+import (
+	"os"
+	"testing"
+
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	// The helpers log through logger.Log, which is nil until initialised.
+	logger.InitLogger()
+	logger.Log.SetLevel(logrus.WarnLevel)
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
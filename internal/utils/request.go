@@ -1,21 +1,161 @@
 package utils
 
 import (
-	"net/http"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"subscription-aggregator/internal/apperror"
 	"subscription-aggregator/pkg/logger"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
-func BindJSONOrAbort[T any](context *gin.Context, target *T) bool {
+func init() {
+	// Report validation errors by JSON or query parameter name rather than Go
+	// field name.
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+	}
+}
+
+// IsJSON reports whether the request carries a JSON body.
+func IsJSON(context *gin.Context) bool {
+	return context.ContentType() == binding.MIMEJSON && context.Request.ContentLength != 0
+}
+
+func BindJSON[T any](context *gin.Context, target *T) error {
 	logger.Log.Infof("Attempting to bind JSON to %T", target)
 	if err := context.ShouldBindJSON(target); err != nil {
 		logger.Log.Errorf("Failed to bind JSON: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid JSON: " + err.Error(),
-		})
-		return false
+		if fields := fieldErrors(err); len(fields) > 0 {
+			return FieldErrors(fields)
+		}
+		return apperror.Wrap(apperror.KindValidation, "invalid JSON", err)
 	}
 	logger.Log.Infof("Successfully bound JSON to %T", target)
-	return true
+	return nil
+}
+
+func BindQuery[T any](context *gin.Context, target *T) error {
+	logger.Log.Infof("Attempting to bind query to %T", target)
+	if err := context.ShouldBindQuery(target); err != nil {
+		logger.Log.Errorf("Failed to bind query: %v", err)
+		if fields := fieldErrors(err); len(fields) > 0 {
+			return FieldErrors(fields)
+		}
+		return apperror.Wrap(apperror.KindValidation, "invalid query", err)
+	}
+	logger.Log.Infof("Successfully bound query to %T", target)
+	return nil
+}
+
+// FieldErrors returns a validation error with a message per invalid field,
+// or nil when there are no invalid fields.
+func FieldErrors(fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	logger.Log.Warnf("Validation failed: %v", fields)
+	return apperror.Validation("validation failed", fields)
+}
+
+func fieldErrors(err error) map[string]string {
+	fields := make(map[string]string)
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		for _, fieldErr := range validationErrs {
+			fields[fieldErr.Field()] = validationMessage(fieldErr)
+		}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		fields[typeErr.Field] = "must be of type " + typeErr.Type.String()
+	}
+
+	return fields
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min", "max":
+		bound := "at least "
+		if fieldErr.Tag() == "max" {
+			bound = "at most "
+		}
+		if fieldErr.Kind() == reflect.String {
+			return "must be " + bound + fieldErr.Param() + " characters long"
+		}
+		return "must be " + bound + fieldErr.Param()
+	case "len":
+		return "must be " + fieldErr.Param() + " characters long"
+	case "alpha":
+		return "must contain only letters"
+	case "oneof":
+		return "must be one of: " + fieldErr.Param()
+	}
+	return "failed on the '" + fieldErr.Tag() + "' rule"
+}
+
+// BindMergePatch reads an RFC 7396 merge patch. Members with values are
+// decoded and validated into target, the names of members set to null are
+// returned so that the caller can remove them.
+func BindMergePatch[T any](context *gin.Context, target *T) ([]string, error) {
+	logger.Log.Infof("Attempting to bind merge patch to %T", target)
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(context.Request.Body).Decode(&patch); err != nil || patch == nil {
+		logger.Log.Errorf("Failed to read merge patch: %v", err)
+		return nil, apperror.Validation("merge patch must be a JSON object", nil)
+	}
+
+	var nulls []string
+	values := make(map[string]json.RawMessage, len(patch))
+	for name, value := range patch {
+		if string(bytes.TrimSpace(value)) == "null" {
+			nulls = append(nulls, name)
+			continue
+		}
+		values[name] = value
+	}
+
+	data, err := json.Marshal(values)
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(target)
+	}
+	if err == nil {
+		err = binding.Validator.ValidateStruct(target)
+	}
+	if err != nil {
+		logger.Log.Errorf("Failed to bind merge patch: %v", err)
+		if fields := fieldErrors(err); len(fields) > 0 {
+			return nil, FieldErrors(fields)
+		}
+		return nil, apperror.Wrap(apperror.KindValidation, "invalid JSON", err)
+	}
+
+	sort.Strings(nulls)
+	logger.Log.Infof("Successfully bound merge patch to %T, removed members: %v", target, nulls)
+	return nulls, nil
 }
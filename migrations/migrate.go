@@ -1,12 +1,350 @@
+// Package migrations applies the numbered SQL migrations embedded in the
+// binary and records them in the schema_migrations table.
 package migrations
 
 import (
-	"subscription-aggregator/internal/model"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"subscription-aggregator/pkg/logger"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-func AutoMigrate(db *gorm.DB) error {
-	db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)
-	return db.AutoMigrate(&model.Subscription{})
+//go:embed sql/*.sql
+var files embed.FS
+
+// fileName matches migration files: 0001_initial_schema.up.sql and the
+// matching .down.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// lockKey identifies the advisory lock that keeps concurrent migrations from
+// running at the same time.
+const lockKey = 7310593614203562849
+
+var (
+	ErrPending  = errors.New("database schema is not up to date")
+	ErrModified = errors.New("applied migration was modified")
+	ErrUnknown  = errors.New("database schema is newer than this binary")
+)
+
+// Migration is one schema change with the SQL applying and reverting it.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
+
+// Status describes a migration known to the binary or recorded in the
+// database.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Modified  bool // applied with a different checksum
+	Unknown   bool // applied, but not embedded in this binary
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Load reads the embedded migrations in version order.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := files.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", m.Name, match[2], version)
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+			sum := sha256.Sum256(data)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Version == 0 || m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs a non-zero version and both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator moves the database schema between versions.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	logger.Log.Info("Creating new Migrator")
+	migrations, err := Load()
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: %w", err)
+	}
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    bigint PRIMARY KEY,
+			name       text NOT NULL,
+			checksum   char(64) NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`).Error; err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Status lists the embedded migrations, and the applied ones this binary
+// does not know, in version order.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			status.Modified = row.Checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check returns an error unless every embedded migration is applied
+// unchanged and no others are.
+func (m *Migrator) Check() error {
+	statuses, err := m.checkApplied()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("%w: migration %04d_%s is pending", ErrPending, status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	return m.To(m.latest())
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("number of migrations to revert must be positive, got %d", n)
+	}
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return 0, err
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if n >= len(versions) {
+		return m.To(0)
+	}
+	return m.To(versions[n])
+}
+
+// To applies or reverts migrations until version is the last applied one;
+// version 0 reverts all of them.
+func (m *Migrator) To(version int) (int, error) {
+	if version != 0 && m.find(version) == nil {
+		return 0, fmt.Errorf("unknown migration version %d", version)
+	}
+	if _, err := m.checkApplied(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		done, err := m.apply(migration)
+		if err != nil {
+			return count, err
+		}
+		if done {
+			count++
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0 && m.migrations[i].Version > version; i-- {
+		done, err := m.revert(m.migrations[i])
+		if err != nil {
+			return count, err
+		}
+		if done {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// checkApplied returns the migration statuses, refusing to touch a schema
+// with modified or unknown migrations.
+func (m *Migrator) checkApplied() ([]Status, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		switch {
+		case status.Unknown:
+			return nil, fmt.Errorf("%w: migration %04d_%s", ErrUnknown, status.Version, status.Name)
+		case status.Modified:
+			return nil, fmt.Errorf("%w: %04d_%s", ErrModified, status.Version, status.Name)
+		}
+	}
+	return statuses, nil
+}
+
+// apply runs the up SQL of migration unless it is already applied. The
+// migration and its record are committed together; the advisory lock makes
+// concurrent migrators wait for each other.
+func (m *Migrator) apply(migration Migration) (bool, error) {
+	done := false
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+		if _, ok := applied[migration.Version]; ok {
+			return nil
+		}
+
+		logger.Log.Infof("Applying migration %04d_%s", migration.Version, migration.Name)
+		if err := tx.Exec(migration.Up).Error; err != nil {
+			return err
+		}
+		done = true
+		return tx.Create(&appliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		logger.Log.Errorf("Error applying migration %04d_%s: %v", migration.Version, migration.Name, err)
+		return false, fmt.Errorf("apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return done, nil
+}
+
+// revert runs the down SQL of migration if it is applied.
+func (m *Migrator) revert(migration Migration) (bool, error) {
+	done := false
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			return nil
+		}
+
+		logger.Log.Infof("Reverting migration %04d_%s", migration.Version, migration.Name)
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+		done = true
+		return tx.Delete(&appliedMigration{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		logger.Log.Errorf("Error reverting migration %04d_%s: %v", migration.Version, migration.Name, err)
+		return false, fmt.Errorf("revert migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return done, nil
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[int]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	applied := make(map[int]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
 }
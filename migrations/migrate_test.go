@@ -0,0 +1,155 @@
+package migrations_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"subscription-aggregator/internal/model"
+	"subscription-aggregator/internal/repository"
+	"subscription-aggregator/migrations"
+	"subscription-aggregator/pkg/database"
+	"subscription-aggregator/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// postgresDSNEnv names the variable with the DSN of a PostgreSQL database the
+// tests may use. They create and drop a schema of their own in it.
+const postgresDSNEnv = "SUBAGG_TEST_POSTGRES_DSN"
+
+// baselineSchema is the subscriptions table GORM AutoMigrate created in the
+// first release: whole-ruble prices and no users.
+const baselineSchema = `
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE TABLE subscriptions (
+    user_id      varchar(255),
+    id           uuid DEFAULT uuid_generate_v4(),
+    service_name text,
+    price        bigint,
+    start_date   timestamptz,
+    end_date     timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_subscriptions_user_id ON subscriptions (user_id);
+CREATE INDEX idx_subscriptions_service_name ON subscriptions (service_name);
+CREATE INDEX idx_subscriptions_price ON subscriptions (price);
+CREATE INDEX idx_subscriptions_start_date ON subscriptions (start_date);
+CREATE INDEX idx_subscriptions_end_date ON subscriptions (end_date);`
+
+func TestMain(m *testing.M) {
+	// The migrator logs through logger.Log, which is nil until initialised.
+	logger.InitLogger()
+	logger.Log.SetLevel(logrus.WarnLevel)
+	os.Exit(m.Run())
+}
+
+// openSchema returns a connection to a new, empty schema of the test
+// database that is dropped when the test ends.
+func openSchema(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	admin := database.InitDB(dsn)
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse DSN: %v", err)
+	}
+	// public stays on the path for the uuid-ossp functions.
+	config.RuntimeParams["search_path"] = schema + ", public"
+	sqlDB := stdlib.OpenDB(*config)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open schema: %v", err)
+	}
+	return db
+}
+
+// TestUpgradeBaselineSchema migrates a database of the first release and
+// checks that its subscriptions are usable afterwards.
+func TestUpgradeBaselineSchema(t *testing.T) {
+	db := openSchema(t)
+	if err := db.Exec(baselineSchema).Error; err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	legacy := []struct {
+		id     uuid.UUID
+		userID string
+		price  int64
+	}{
+		{uuid.New(), "anna", 299},
+		{uuid.New(), "anna", 1},
+		{uuid.New(), "boris", 0},
+	}
+	for _, sub := range legacy {
+		err := db.Exec(`INSERT INTO subscriptions (id, user_id, service_name, price, start_date) VALUES (?, ?, 'service', ?, ?)`,
+			sub.id, sub.userID, sub.price, start).Error
+		if err != nil {
+			t.Fatalf("insert baseline subscription: %v", err)
+		}
+	}
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+
+	var users int64
+	if err := db.Model(&model.User{}).Where("id IN ?", []string{"anna", "boris"}).Count(&users).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if users != 2 {
+		t.Errorf("%d users backfilled, want 2", users)
+	}
+
+	repo := repository.NewSubscriptionRepository(db)
+	ctx := context.Background()
+	for _, want := range legacy {
+		sub, err := repo.GetByID(ctx, want.id)
+		if err != nil {
+			t.Fatalf("GetByID(%s): %v", want.id, err)
+		}
+		if sub.Price != model.Money(want.price*100) || sub.Currency != model.DefaultCurrency ||
+			sub.BillingPeriod != model.BillingMonthly || sub.Version != 1 || sub.DeletedAt.Valid {
+			t.Errorf("upgraded subscription = %+v, want price %d00 RUB monthly at version 1", sub, want.price)
+		}
+		if len(sub.PriceChanges) != 1 || sub.PriceChanges[0].Price != sub.Price || !sub.PriceChanges[0].EffectiveFrom.Equal(start) {
+			t.Errorf("price history = %+v, want the price from %s", sub.PriceChanges, start)
+		}
+	}
+
+	orphan := &model.Subscription{UserID: "nobody", ServiceName: "service", Currency: model.DefaultCurrency, StartDate: start}
+	if err := repo.Create(ctx, orphan); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Create for an unknown user = %v, want %v", err, repository.ErrConflict)
+	}
+}
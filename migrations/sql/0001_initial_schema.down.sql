@@ -0,0 +1,13 @@
+-- Drops every table of the service together with its data. The uuid-ossp
+-- extension is left in place, other schemas may use it.
+
+DROP TABLE IF EXISTS audit_entries;
+DROP FUNCTION IF EXISTS audit_entries_append_only();
+DROP TABLE IF EXISTS split_shares;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS price_changes;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,175 @@
+-- Schema as created by GORM AutoMigrate before versioned migrations. Every
+-- statement is idempotent, and subscriptions tables of the first release,
+-- with whole-ruble prices and no users, are upgraded in place, so databases
+-- set up by earlier releases adopt it.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id         varchar(255) PRIMARY KEY,
+    name       varchar(255),
+    email      varchar(255),
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS groups (
+    id         uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    name       varchar(255) NOT NULL,
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id   uuid,
+    user_id    varchar(255),
+    role       varchar(16) NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (group_id, user_id),
+    CONSTRAINT fk_groups_members FOREIGN KEY (group_id) REFERENCES groups (id) ON DELETE CASCADE,
+    CONSTRAINT fk_group_members_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_id             varchar(255),
+    group_id            uuid,
+    id                  uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    service_name        text,
+    price_minor         bigint,
+    currency            varchar(3) NOT NULL DEFAULT 'RUB',
+    billing_period      varchar(16) NOT NULL DEFAULT 'monthly',
+    billing_period_days bigint NOT NULL DEFAULT 0,
+    start_date          timestamptz,
+    end_date            timestamptz,
+    version             bigint NOT NULL DEFAULT 1,
+    deleted_at          timestamptz,
+    split_method        varchar(16) NOT NULL DEFAULT '',
+    CONSTRAINT fk_subscriptions_group FOREIGN KEY (group_id) REFERENCES groups (id) ON DELETE SET NULL,
+    CONSTRAINT fk_subscriptions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Upgrade a subscriptions table of the first release: add the later columns,
+-- move prices to minor units and add the users they belong to.
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS group_id uuid,
+    ADD COLUMN IF NOT EXISTS price_minor bigint,
+    ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT 'RUB',
+    ADD COLUMN IF NOT EXISTS billing_period varchar(16) NOT NULL DEFAULT 'monthly',
+    ADD COLUMN IF NOT EXISTS billing_period_days bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz,
+    ADD COLUMN IF NOT EXISTS split_method varchar(16) NOT NULL DEFAULT '';
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'subscriptions' AND column_name = 'price'
+    ) THEN
+        UPDATE subscriptions SET price_minor = ROUND(price * 100);
+        ALTER TABLE subscriptions DROP COLUMN price;
+    END IF;
+END
+$$;
+
+INSERT INTO users (id, created_at)
+SELECT DISTINCT user_id, now()
+FROM subscriptions
+WHERE user_id IS NOT NULL
+ON CONFLICT (id) DO NOTHING;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'subscriptions'::regclass AND conname = 'fk_subscriptions_group') THEN
+        ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_group
+            FOREIGN KEY (group_id) REFERENCES groups (id) ON DELETE SET NULL;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'subscriptions'::regclass AND conname = 'fk_subscriptions_user') THEN
+        ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_user
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE;
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date ON subscriptions (end_date);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date ON subscriptions (start_date);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_price ON subscriptions (price_minor);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name ON subscriptions (service_name);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_group_id ON subscriptions (group_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions (user_id);
+
+CREATE TABLE IF NOT EXISTS price_changes (
+    id              uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    subscription_id uuid NOT NULL,
+    price_minor     bigint NOT NULL,
+    effective_from  timestamptz NOT NULL,
+    created_at      timestamptz,
+    CONSTRAINT fk_subscriptions_price_changes FOREIGN KEY (subscription_id) REFERENCES subscriptions (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_changes_subscription_date ON price_changes (subscription_id, effective_from);
+
+-- Subscriptions created before price history existed start with their
+-- current price.
+INSERT INTO price_changes (subscription_id, price_minor, effective_from, created_at)
+SELECT id, price_minor, start_date, now()
+FROM subscriptions AS s
+WHERE NOT EXISTS (SELECT 1 FROM price_changes AS p WHERE p.subscription_id = s.id);
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency   varchar(3) PRIMARY KEY,
+    rate       numeric(20, 8) NOT NULL,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    user_id      varchar(255) NOT NULL,
+    name         varchar(255),
+    prefix       varchar(16) NOT NULL,
+    hash         char(64) NOT NULL,
+    scope        varchar(16) NOT NULL,
+    created_at   timestamptz,
+    last_used_at timestamptz,
+    revoked_at   timestamptz,
+    CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_hash ON api_keys (hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS split_shares (
+    subscription_id uuid,
+    user_id         varchar(255),
+    percent         numeric(5, 2),
+    amount_minor    bigint,
+    PRIMARY KEY (subscription_id, user_id),
+    CONSTRAINT fk_subscriptions_shares FOREIGN KEY (subscription_id) REFERENCES subscriptions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_split_shares_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_split_shares_user_id ON split_shares (user_id);
+
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id              bigserial PRIMARY KEY,
+    subscription_id uuid NOT NULL,
+    user_id         varchar(255) NOT NULL,
+    action          varchar(16) NOT NULL,
+    actor           varchar(255),
+    request_id      varchar(64),
+    changes         jsonb NOT NULL,
+    created_at      timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_created_at ON audit_entries (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_actor ON audit_entries (actor);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_user_id ON audit_entries (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_subscription_id ON audit_entries (subscription_id);
+
+-- The audit log is append-only: updates and deletes of its rows fail.
+CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_entries is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries;
+CREATE TRIGGER audit_entries_append_only
+    BEFORE UPDATE OR DELETE ON audit_entries
+    FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only();
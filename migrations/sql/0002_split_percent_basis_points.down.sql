@@ -0,0 +1,5 @@
+-- Restores the percentages with two decimals.
+
+ALTER TABLE split_shares ADD COLUMN percent numeric(5, 2);
+UPDATE split_shares SET percent = percent_bp / 100.0 WHERE percent_bp IS NOT NULL;
+ALTER TABLE split_shares DROP COLUMN percent_bp;
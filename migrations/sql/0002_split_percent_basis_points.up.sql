@@ -0,0 +1,6 @@
+-- Split percentages are stored as basis points, hundredths of a percent, so
+-- that shares are calculated without floating point.
+
+ALTER TABLE split_shares ADD COLUMN percent_bp integer;
+UPDATE split_shares SET percent_bp = ROUND(percent * 100) WHERE percent IS NOT NULL;
+ALTER TABLE split_shares DROP COLUMN percent;
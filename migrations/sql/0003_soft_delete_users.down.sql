@@ -0,0 +1,10 @@
+-- Restores the cascading foreign key and removes the users marked deleted
+-- together with their subscriptions, as deleting a user did before.
+
+ALTER TABLE subscriptions DROP CONSTRAINT fk_subscriptions_user;
+ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_user
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,10 @@
+-- Deleted users are marked deleted and removed by the retention purge once
+-- none of their subscriptions are left, so removing a user can no longer
+-- take subscriptions with it.
+
+ALTER TABLE users ADD COLUMN deleted_at timestamptz;
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+ALTER TABLE subscriptions DROP CONSTRAINT fk_subscriptions_user;
+ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_user
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT ON UPDATE CASCADE;
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"subscription-aggregator/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sqlite/schema.sql
+var sqliteSchema string
+
+// CreateSQLiteSchema creates the tables that are missing in a SQLite
+// database. SQLite databases are meant for local runs and tests, so their
+// schema is not versioned.
+func CreateSQLiteSchema(db *gorm.DB) error {
+	logger.Log.Info("Creating SQLite schema")
+	if err := upgradeSQLiteUsers(db); err != nil {
+		return fmt.Errorf("upgrade SQLite schema: %w", err)
+	}
+	if err := db.Exec(sqliteSchema).Error; err != nil {
+		return fmt.Errorf("create SQLite schema: %w", err)
+	}
+	if err := upgradeSQLiteSplitPercent(db); err != nil {
+		return fmt.Errorf("upgrade SQLite schema: %w", err)
+	}
+	return nil
+}
+
+// upgradeSQLiteSplitPercent moves the split percentages of databases created
+// before they were stored in basis points into the percent_bp column, like
+// migration 0002 does for PostgreSQL.
+func upgradeSQLiteSplitPercent(db *gorm.DB) error {
+	var outdated int64
+	err := db.Raw("SELECT COUNT(*) FROM pragma_table_info('split_shares') WHERE name = 'percent'").Scan(&outdated).Error
+	if err != nil || outdated == 0 {
+		return err
+	}
+	logger.Log.Info("Converting split percentages to basis points")
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			"ALTER TABLE split_shares ADD COLUMN percent_bp integer",
+			"UPDATE split_shares SET percent_bp = CAST(ROUND(percent * 100) AS integer) WHERE percent IS NOT NULL",
+			"ALTER TABLE split_shares DROP COLUMN percent",
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// upgradeSQLiteUsers adds the deleted_at column to users tables created
+// before users were marked deleted. It runs before the schema, whose index
+// on the column needs it. The subscriptions of such databases keep their
+// cascading foreign key, which the purge never triggers.
+func upgradeSQLiteUsers(db *gorm.DB) error {
+	var columns []string
+	if err := db.Raw("SELECT name FROM pragma_table_info('users')").Scan(&columns).Error; err != nil {
+		return err
+	}
+	if len(columns) == 0 || slices.Contains(columns, "deleted_at") {
+		return nil
+	}
+	logger.Log.Info("Adding deleted_at to users")
+	return db.Exec("ALTER TABLE users ADD COLUMN deleted_at datetime").Error
+}
@@ -0,0 +1,115 @@
+-- SQLite version of the schema for local runs and tests. It is not versioned:
+-- every statement is idempotent and runs on startup, so a SQLite database
+-- does not outlive schema changes that need more than a new table or index.
+-- uuid_generate_v4() and now() are registered by the application.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         varchar(255) PRIMARY KEY,
+    name       varchar(255),
+    email      varchar(255),
+    created_at datetime,
+    deleted_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS groups (
+    id         uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    name       varchar(255) NOT NULL,
+    created_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id   uuid REFERENCES groups (id) ON DELETE CASCADE,
+    user_id    varchar(255) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role       varchar(16) NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_id             varchar(255) REFERENCES users (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    group_id            uuid REFERENCES groups (id) ON DELETE SET NULL,
+    id                  uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    service_name        text,
+    price_minor         bigint,
+    currency            varchar(3) NOT NULL DEFAULT 'RUB',
+    billing_period      varchar(16) NOT NULL DEFAULT 'monthly',
+    billing_period_days bigint NOT NULL DEFAULT 0,
+    start_date          datetime,
+    end_date            datetime,
+    version             bigint NOT NULL DEFAULT 1,
+    deleted_at          datetime,
+    split_method        varchar(16) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date ON subscriptions (end_date);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date ON subscriptions (start_date);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_price ON subscriptions (price_minor);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name ON subscriptions (service_name);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_group_id ON subscriptions (group_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions (user_id);
+
+CREATE TABLE IF NOT EXISTS price_changes (
+    id              uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    price_minor     bigint NOT NULL,
+    effective_from  datetime NOT NULL,
+    created_at      datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_changes_subscription_date ON price_changes (subscription_id, effective_from);
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency   varchar(3) PRIMARY KEY,
+    rate       numeric NOT NULL,
+    updated_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    user_id      varchar(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    name         varchar(255),
+    prefix       varchar(16) NOT NULL,
+    hash         char(64) NOT NULL,
+    scope        varchar(16) NOT NULL,
+    created_at   datetime,
+    last_used_at datetime,
+    revoked_at   datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_hash ON api_keys (hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS split_shares (
+    subscription_id uuid REFERENCES subscriptions (id) ON DELETE CASCADE,
+    user_id         varchar(255) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    percent_bp      integer,
+    amount_minor    bigint,
+    PRIMARY KEY (subscription_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_split_shares_user_id ON split_shares (user_id);
+
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    subscription_id uuid NOT NULL,
+    user_id         varchar(255) NOT NULL,
+    action          varchar(16) NOT NULL,
+    actor           varchar(255),
+    request_id      varchar(64),
+    changes         text NOT NULL,
+    created_at      datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_created_at ON audit_entries (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_actor ON audit_entries (actor);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_user_id ON audit_entries (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_subscription_id ON audit_entries (subscription_id);
+
+-- The audit log is append-only: updates and deletes of its rows fail.
+CREATE TRIGGER IF NOT EXISTS audit_entries_no_update BEFORE UPDATE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
@@ -0,0 +1,106 @@
+package cursor_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-aggregator/pkg/cursor"
+)
+
+type position struct {
+	StartDate time.Time `json:"s"`
+	ID        string    `json:"i"`
+	Desc      bool      `json:"d,omitempty"`
+}
+
+type query struct {
+	UserID   string
+	SortBy   string
+	SortDesc bool
+}
+
+func TestRoundTrip(t *testing.T) {
+	signer := cursor.NewSigner([]byte("cursor-tests-key"))
+	want := position{StartDate: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: "2f1c", Desc: true}
+	issuedFor := query{UserID: "alice", SortBy: "start_date", SortDesc: true}
+
+	token, err := signer.Encode(issuedFor, want)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got position
+	if err := signer.Decode(token, issuedFor, &got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !got.StartDate.Equal(want.StartDate) || got.ID != want.ID || got.Desc != want.Desc {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+	if strings.ContainsAny(token, "+/= ") {
+		t.Errorf("token %q is not URL-safe", token)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	signer := cursor.NewSigner([]byte("cursor-tests-key"))
+	issuedFor := query{UserID: "alice"}
+	token, err := signer.Encode(issuedFor, position{ID: "2f1c"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"q":"","v":{"i":"ffff"}}`))
+	other, err := cursor.NewSigner([]byte("another-key")).Encode(issuedFor, position{ID: "2f1c"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"without signature", encoded},
+		{"changed payload", forged + "." + signature},
+		{"changed signature", encoded + "." + strings.Repeat("A", len(signature))},
+		{"signature not base64", encoded + ".!!"},
+		{"signed with another key", other},
+		{"signed garbage", "bm90LWpzb24." + signature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got position
+			if err := signer.Decode(test.token, issuedFor, &got); !errors.Is(err, cursor.ErrInvalid) {
+				t.Errorf("Decode = %v, want %v", err, cursor.ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestDifferentQuery(t *testing.T) {
+	signer := cursor.NewSigner([]byte("cursor-tests-key"))
+	issuedFor := query{UserID: "alice", SortBy: "start_date"}
+	token, err := signer.Encode(issuedFor, position{ID: "2f1c"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query query
+	}{
+		{"other filter", query{UserID: "bob", SortBy: "start_date"}},
+		{"other sort", query{UserID: "alice", SortBy: "start_date", SortDesc: true}},
+		{"other sort field", query{UserID: "alice", SortBy: "price"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got position
+			if err := signer.Decode(token, test.query, &got); !errors.Is(err, cursor.ErrMismatch) {
+				t.Errorf("Decode = %v, want %v", err, cursor.ErrMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteDriver is go-sqlite3 with the PostgreSQL functions the models and
+// queries rely on: uuid_generate_v4() for generated IDs and now().
+const sqliteDriver = "sqlite3_subscription_aggregator"
+
+// sqliteTimeFormat is the format go-sqlite3 writes times in. Text in this
+// format compares in time order as long as the zone is the same.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("uuid_generate_v4", uuid.NewString, false); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("now", func() string {
+				return time.Now().UTC().Format(sqliteTimeFormat)
+			}, false)
+		},
+	})
+}
+
+// InitSQLite opens the SQLite database at path; ":memory:" keeps it in the
+// process memory. Times are stored as text, so they are written in UTC to
+// keep them comparable. SQLite needs a binary built with cgo.
+func InitSQLite(path string) *gorm.DB {
+	dsn := "file:" + path + "?_foreign_keys=1&_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL"
+	if path == ":memory:" {
+		dsn = "file::memory:?_foreign_keys=1"
+	}
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: sqliteDriver, DSN: dsn}), &gorm.Config{
+		TranslateError: true,
+		NowFunc:        func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		log.Fatalf("database connection error: %v", err)
+	}
+	if path == ":memory:" {
+		// Every connection gets its own in-memory database, so there must
+		// be only one.
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("database connection error: %v", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return db
+}